@@ -3,14 +3,23 @@ package db
 import (
 	"context"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ctx = context.Background()
+// Timeouts bounds how long a single store operation may run. A zero value
+// disables the corresponding deadline, leaving only the caller's context.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	// Statement is sent to Postgres as statement_timeout so the server also
+	// aborts runaway queries when the client side deadline is lost.
+	Statement time.Duration
+}
 
-func InitDB(URLDatabase string) *pgxpool.Pool {
+func InitDB(ctx context.Context, URLDatabase string, timeouts Timeouts) *pgxpool.Pool {
 	const defaultMaxConns = int32(10)
 	const defaultMinConns = int32(2)
 	const defaultMaxConnLifetime = time.Hour
@@ -30,6 +39,10 @@ func InitDB(URLDatabase string) *pgxpool.Pool {
 	dbConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 	dbConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
 
+	if timeouts.Statement > 0 {
+		dbConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(timeouts.Statement.Milliseconds(), 10)
+	}
+
 	dbPool, err := pgxpool.NewWithConfig(ctx, dbConfig)
 	if err != nil {
 		log.Fatal("Failed to create config database, error:", err)
@@ -37,3 +50,61 @@ func InitDB(URLDatabase string) *pgxpool.Pool {
 
 	return dbPool
 }
+
+// PostgresStore is the pgx-backed implementation of Store.
+type PostgresStore struct {
+	pool     *pgxpool.Pool
+	timeouts Timeouts
+	// searchLanguage is the text search configuration used to index new
+	// content and to parse search queries, one of SearchLanguages.
+	searchLanguage string
+}
+
+func NewPostgresStore(pool *pgxpool.Pool, timeouts Timeouts, searchLanguage string) *PostgresStore {
+	return &PostgresStore{pool: pool, timeouts: timeouts, searchLanguage: searchLanguage}
+}
+
+// count runs a SELECT count(*) query and returns the result.
+func (s *PostgresStore) count(ctx context.Context, query string, args ...any) (int, error) {
+	var total int
+	if err := s.pool.QueryRow(ctx, query, args...).Scan(&total); err != nil {
+		return 0, mapError(err)
+	}
+	return total, nil
+}
+
+// missingOrStale explains why a conditional UPDATE matched no rows: the row
+// either does not exist or is no longer at one of the expected versions.
+// existsQuery must select a single boolean for the id.
+func (s *PostgresStore) missingOrStale(ctx context.Context, existsQuery string, id string, ifVersion []int) error {
+	if len(ifVersion) == 0 {
+		return ErrNotFound
+	}
+
+	var exists bool
+	if err := s.pool.QueryRow(ctx, existsQuery, id).Scan(&exists); err != nil {
+		return mapError(err)
+	}
+	if exists {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+// readContext derives the context for a read query from the request context.
+func (s *PostgresStore) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.timeouts.Read)
+}
+
+// writeContext derives the context for a write statement from the request
+// context.
+func (s *PostgresStore) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.timeouts.Write)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
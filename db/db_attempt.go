@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gopher-post/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) GetLoginAttempt(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1"
+
+	var attempt models.LoginAttempt
+	err := s.pool.QueryRow(ctx, query, key).Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailureAt, &attempt.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.LoginAttempt{Key: key}, nil
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &attempt, nil
+}
+
+func (s *PostgresStore) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempt, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	// the upsert makes concurrent failures of the same key count one by one
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < now() - $2::interval
+				THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = now()
+		RETURNING key, failures, last_failure_at, locked_until`
+
+	var attempt models.LoginAttempt
+	err := s.pool.QueryRow(ctx, query, key, window).Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailureAt, &attempt.LockedUntil)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &attempt, nil
+}
+
+func (s *PostgresStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO login_attempts (key, locked_until) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET locked_until = EXCLUDED.locked_until`
+
+	_, err := s.pool.Exec(ctx, query, key, until)
+	return mapError(err)
+}
+
+func (s *PostgresStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return mapError(err)
+}
+
+func (s *PostgresStore) PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until <= now())`
+
+	tag, err := s.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -1,17 +1,28 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	"gopher-post/models"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func GetCommentByPostID(dbpool *pgxpool.Pool, postID string) (*[]models.Comment, error) {
-	query := "SELECT id, content, user_id, post_id, created_at FROM comments WHERE post_id = $1"
+func (s *PostgresStore) GetCommentByPostID(ctx context.Context, postID string, page PageRequest) (*Page[models.Comment], error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	cond, order, args := page.keyset(2)
+	// comments of a trashed post are hidden along with it
+	const where = `WHERE post_id = $1 AND deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NULL)`
+	query := "SELECT id, content, user_id, post_id, created_at FROM comments " + where
+	if cond != "" {
+		query += " AND " + cond
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", order, page.limit()+1)
 
-	rows, err := dbpool.Query(ctx, query, postID)
+	rows, err := s.pool.Query(ctx, query, append([]any{postID}, args...)...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
@@ -19,44 +30,58 @@ func GetCommentByPostID(dbpool *pgxpool.Pool, postID string) (*[]models.Comment,
 	for rows.Next() {
 		var comment models.Comment
 		if err := rows.Scan(&comment.ID, &comment.Content, &comment.UserID, &comment.PostID, &comment.CreatedAt); err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
 
 		comments = append(comments, comment)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	result := buildPage(comments, page, commentCursor)
+	if page.WithTotal {
+		total, err := s.count(ctx, "SELECT count(*) FROM comments "+where, postID)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
 
-	return &comments, err
+	return result, nil
 }
 
-func GetCommentOwnerID(dbpool *pgxpool.Pool, id string) (string, error) {
-	query := "SELECT user_id FROM comments WHERE id = $1"
+func (s *PostgresStore) GetCommentOwnerIDs(ctx context.Context, id string) (string, string, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
 
-	var userID string
-	err := dbpool.QueryRow(ctx, query, id).Scan(&userID)
+	query := `SELECT c.user_id, p.user_id FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.id = $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL`
+
+	var commentOwnerID, postOwnerID string
+	err := s.pool.QueryRow(ctx, query, id).Scan(&commentOwnerID, &postOwnerID)
 	if err != nil {
-		return "", err
+		return "", "", mapError(err)
 	}
-	return userID, err
+	return commentOwnerID, postOwnerID, nil
 }
 
-func CreateCommentInDB(dbpool *pgxpool.Pool, comment string, userID string, postID string) error {
-	query := "INSERT INTO comments (content, user_id, post_id) VALUES ($1, $2, $3)"
+func (s *PostgresStore) CreateCommentInDB(ctx context.Context, comment string, userID string, postID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, comment, userID, postID)
-	if err != nil {
-		return err
-	}
+	// inserting through a SELECT keeps comments off posts sitting in the trash
+	query := `INSERT INTO comments (content, user_id, post_id, search_config)
+		SELECT $1::text, $2::uuid, id, $4::regconfig FROM posts WHERE id = $3 AND deleted_at IS NULL`
 
-	return nil
+	return checkAffected(s.pool.Exec(ctx, query, comment, userID, postID, s.searchLanguage))
 }
 
-func DeleteCommentByID(dbpool *pgxpool.Pool, id string) error {
-	query := "DELETE FROM comments WHERE id = $1"
+func (s *PostgresStore) DeleteCommentByID(ctx context.Context, id string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, id)
-	if err != nil {
-		return err
-	}
+	query := "UPDATE comments SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL"
 
-	return nil
+	return checkAffected(s.pool.Exec(ctx, query, id))
 }
@@ -0,0 +1,26 @@
+package db
+
+import "context"
+
+func (s *PostgresStore) GetIdentityUserID(ctx context.Context, provider string, subject string) (string, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2"
+
+	var userID string
+	if err := s.pool.QueryRow(ctx, query, provider, subject).Scan(&userID); err != nil {
+		return "", mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *PostgresStore) LinkIdentity(ctx context.Context, userID string, provider string, subject string, email string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)"
+
+	_, err := s.pool.Exec(ctx, query, userID, provider, subject, email)
+	return mapError(err)
+}
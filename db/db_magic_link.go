@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) CreateMagicLink(ctx context.Context, userID string, expiresAt time.Time) (string, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	var id string
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM magic_links WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, `INSERT INTO magic_links (user_id, expires_at) VALUES ($1, $2)
+			RETURNING id`, userID, expiresAt).Scan(&id)
+	})
+	if err != nil {
+		return "", mapError(err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStore) UseMagicLink(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `UPDATE magic_links SET used_at = now()
+		WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > now()`
+
+	return checkAffected(s.pool.Exec(ctx, query, id, userID))
+}
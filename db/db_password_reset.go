@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) CreatePasswordReset(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	return mapError(pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `INSERT INTO password_resets (user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)`, userID, tokenHash, expiresAt)
+		return err
+	}))
+}
+
+func (s *PostgresStore) CheckPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM password_resets WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()"
+
+	var userID string
+	err := s.pool.QueryRow(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		return "", mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *PostgresStore) ResetPassword(ctx context.Context, tokenHash string, passwordHash string) (string, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	// consuming the token and updating the password happen in one statement,
+	// so a token can never be used twice
+	query := `WITH reset AS (
+			UPDATE password_resets SET used_at = now()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			RETURNING user_id
+		)
+		UPDATE users SET password_hash = $2, token_version = token_version + 1
+		WHERE id = (SELECT user_id FROM reset)
+		RETURNING id`
+
+	var userID string
+	err := s.pool.QueryRow(ctx, query, tokenHash, passwordHash).Scan(&userID)
+	if err != nil {
+		return "", mapError(err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+	"time"
+)
+
+func (s *PostgresStore) CreatePersonalToken(ctx context.Context, userID string, name string, tokenHash string, scopes []string, expiresAt time.Time) (*models.PersonalToken, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO personal_tokens (user_id, name, token_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, user_id, name, scopes, created_at, expires_at`
+
+	var token models.PersonalToken
+	err := s.pool.QueryRow(ctx, query, userID, name, tokenHash, scopes, expiresAt).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Scopes,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &token, nil
+}
+
+func (s *PostgresStore) GetPersonalTokens(ctx context.Context, userID string) (*[]models.PersonalToken, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, name, scopes, created_at, expires_at, last_used_at FROM personal_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY created_at DESC`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalToken{}
+	for rows.Next() {
+		var token models.PersonalToken
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.Scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt); err != nil {
+			return nil, mapError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	return &tokens, nil
+}
+
+func (s *PostgresStore) AuthenticatePersonalToken(ctx context.Context, tokenHash string) (*models.PersonalToken, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	// last_used_at is only written once per sessionTouchInterval, like sessions
+	query := `
+		WITH live AS (
+			SELECT t.id, t.user_id, t.name, t.scopes, t.created_at, t.expires_at, t.last_used_at, u.role
+			FROM personal_tokens t
+			JOIN users u ON u.id = t.user_id
+			WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND t.expires_at > now()
+		), touched AS (
+			UPDATE personal_tokens SET last_used_at = now()
+			FROM live
+			WHERE personal_tokens.id = live.id
+				AND (live.last_used_at IS NULL OR live.last_used_at < now() - $2::interval)
+		)
+		SELECT id, user_id, name, scopes, created_at, expires_at, last_used_at, role FROM live`
+
+	var token models.PersonalToken
+	err := s.pool.QueryRow(ctx, query, tokenHash, sessionTouchInterval).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Scopes,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.UserRole,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &token, nil
+}
+
+func (s *PostgresStore) RevokePersonalToken(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE personal_tokens SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	return checkAffected(s.pool.Exec(ctx, query, id, userID))
+}
+
+func (s *PostgresStore) RevokeAllPersonalTokens(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE personal_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	tag, err := s.pool.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return tag.RowsAffected(), nil
+}
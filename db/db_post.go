@@ -1,17 +1,25 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	"gopher-post/models"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func GetPostAll(dbpool *pgxpool.Pool, limit int, offset int) (*[]models.Post, error) {
-	query := "SELECT id, title, content, user_id, created_at, updated_at FROM posts LIMIT $1 OFFSET $2"
+func (s *PostgresStore) GetPostAll(ctx context.Context, page PageRequest) (*Page[models.Post], error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	cond, order, args := page.keyset(1)
+	query := "SELECT id, title, content, user_id, revision, created_at, updated_at FROM posts WHERE deleted_at IS NULL"
+	if cond != "" {
+		query += " AND " + cond
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", order, page.limit()+1)
 
-	rows, err := dbpool.Query(ctx, query, limit, offset)
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
@@ -19,75 +27,112 @@ func GetPostAll(dbpool *pgxpool.Pool, limit int, offset int) (*[]models.Post, er
 
 	for rows.Next() {
 		var post models.Post
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.UserID, &post.CreatedAt, &post.UpdatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.UserID, &post.Revision, &post.CreatedAt, &post.UpdatedAt); err != nil {
+			return nil, mapError(err)
 		}
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
 
-	return &posts, err
+	result := buildPage(posts, page, postCursor)
+	if page.WithTotal {
+		total, err := s.count(ctx, "SELECT count(*) FROM posts WHERE deleted_at IS NULL")
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
 }
 
-func GetPostByID(dbpool *pgxpool.Pool, id string) (*models.Post, error) {
-	query := "SELECT id, title, content, user_id, created_at, updated_at FROM posts WHERE id = $1"
+func (s *PostgresStore) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT id, title, content, user_id, revision, created_at, updated_at FROM posts WHERE id = $1 AND deleted_at IS NULL"
 
 	var post models.Post
-	err := dbpool.QueryRow(ctx, query, id).Scan(
+	err := s.pool.QueryRow(ctx, query, id).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
 		&post.UserID,
+		&post.Revision,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &post, nil
 }
 
-func GetPostOwnerID(dbpool *pgxpool.Pool, id string) (string, error) {
-	query := "SELECT user_id FROM posts WHERE id = $1"
+func (s *PostgresStore) GetPostOwnerID(ctx context.Context, id string) (string, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL"
 
 	var userID string
-	err := dbpool.QueryRow(ctx, query, id).Scan(&userID)
+	err := s.pool.QueryRow(ctx, query, id).Scan(&userID)
 	if err != nil {
-		return "", err
+		return "", mapError(err)
 	}
 
 	return userID, nil
 }
 
-func CreatePostInDB(dbpool *pgxpool.Pool, title string, content string, user_id string) error {
-	query := "INSERT INTO posts (title, content, user_id) VALUES ($1, $2, $3)"
+func (s *PostgresStore) CreatePostInDB(ctx context.Context, title string, content string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, title, content, user_id)
-	if err != nil {
-		return err
-	}
+	query := `
+		WITH created AS (
+			INSERT INTO posts (title, content, user_id, search_config) VALUES ($1, $2, $3, $4)
+			RETURNING id, revision, title, content, user_id, created_at
+		)
+		INSERT INTO post_revisions (post_id, revision, title, content, editor_id, created_at)
+		SELECT id, revision, title, content, user_id, created_at FROM created`
 
-	return nil
+	_, err := s.pool.Exec(ctx, query, title, content, userID, s.searchLanguage)
+	return mapError(err)
 }
 
-func UpdatePostByID(dbpool *pgxpool.Pool, title string, content string, id string) error {
-	query := "UPDATE posts SET title = $1, content = $2 WHERE id = $3"
-
-	_, err := dbpool.Exec(ctx, query, title, content, id)
+func (s *PostgresStore) UpdatePostByID(ctx context.Context, title string, content string, id string, editorID string, ifVersion []int) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `
+		WITH updated AS (
+			UPDATE posts SET title = $1, content = $2, search_config = $3,
+				revision = revision + 1, updated_at = now()
+			WHERE id = $4 AND deleted_at IS NULL
+				AND (coalesce(cardinality($6::int[]), 0) = 0 OR revision = ANY ($6::int[]))
+			RETURNING id, revision, title, content, updated_at
+		)
+		INSERT INTO post_revisions (post_id, revision, title, content, editor_id, created_at)
+		SELECT id, revision, title, content, $5::uuid, updated_at FROM updated`
+
+	tag, err := s.pool.Exec(ctx, query, title, content, s.searchLanguage, id, editorID, ifVersion)
 	if err != nil {
-		return err
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return s.missingOrStale(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)", id, ifVersion)
 	}
 
 	return nil
 }
 
-func DeletePostByID(dbpool *pgxpool.Pool, id string) error {
-	query := "DELETE FROM posts WHERE id = $1"
+func (s *PostgresStore) DeletePostByID(ctx context.Context, id string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, id)
-	if err != nil {
-		return err
-	}
+	query := "UPDATE posts SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL"
 
-	return nil
+	return checkAffected(s.pool.Exec(ctx, query, id))
 }
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+)
+
+func (s *PostgresStore) GetPostRevisions(ctx context.Context, postID string) (*[]models.PostRevision, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT r.post_id, r.revision, r.title, r.content, COALESCE(r.editor_id::text, ''), r.created_at
+		FROM post_revisions r JOIN posts p ON p.id = r.post_id
+		WHERE r.post_id = $1 AND p.deleted_at IS NULL
+		ORDER BY r.revision`
+
+	rows, err := s.pool.Query(ctx, query, postID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var revisions []models.PostRevision
+	for rows.Next() {
+		var revision models.PostRevision
+		if err := rows.Scan(&revision.PostID, &revision.Revision, &revision.Title, &revision.Content, &revision.EditorID, &revision.CreatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	// every live post has at least its first revision
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &revisions, nil
+}
+
+func (s *PostgresStore) GetPostRevision(ctx context.Context, postID string, revision int) (*models.PostRevision, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT r.post_id, r.revision, r.title, r.content, COALESCE(r.editor_id::text, ''), r.created_at
+		FROM post_revisions r JOIN posts p ON p.id = r.post_id
+		WHERE r.post_id = $1 AND r.revision = $2 AND p.deleted_at IS NULL`
+
+	var result models.PostRevision
+	err := s.pool.QueryRow(ctx, query, postID, revision).Scan(
+		&result.PostID,
+		&result.Revision,
+		&result.Title,
+		&result.Content,
+		&result.EditorID,
+		&result.CreatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &result, nil
+}
+
+func (s *PostgresStore) RollbackPost(ctx context.Context, postID string, revision int, editorID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `
+		WITH target AS (
+			SELECT title, content FROM post_revisions WHERE post_id = $1 AND revision = $2
+		), updated AS (
+			UPDATE posts p SET title = t.title, content = t.content, search_config = $3,
+				revision = p.revision + 1, updated_at = now()
+			FROM target t
+			WHERE p.id = $1 AND p.deleted_at IS NULL
+			RETURNING p.id, p.revision, p.title, p.content, p.updated_at
+		)
+		INSERT INTO post_revisions (post_id, revision, title, content, editor_id, created_at)
+		SELECT id, revision, title, content, $4::uuid, updated_at FROM updated`
+
+	return checkAffected(s.pool.Exec(ctx, query, postID, revision, s.searchLanguage, editorID))
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gopher-post/models"
+	"html"
+	"strings"
+)
+
+// SearchLanguages lists the Postgres text search configurations accepted for
+// SEARCH_LANGUAGE.
+var SearchLanguages = []string{"indonesian", "english", "simple"}
+
+// Matches in snippets are delimited by control characters first. They are
+// turned into <mark> tags only after the content has been HTML-escaped, so
+// markup written in posts reaches clients as text.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=35, MinWords=15, MaxFragments=2`, markStart, markStop)
+
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// markSnippet HTML-escapes snippet and turns the match delimiters into <mark>
+// tags.
+func markSnippet(snippet string) string {
+	return markReplacer.Replace(html.EscapeString(snippet))
+}
+
+func (s *PostgresStore) Search(ctx context.Context, text string, page PageRequest) (*Page[models.SearchResult], error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	args := []any{s.searchLanguage, text, markStart + markStop, headlineOptions}
+
+	order := "rank DESC, created_at DESC, id DESC"
+	cond := ""
+	if page.Cursor != nil {
+		op := "<"
+		if page.backward() {
+			op = ">"
+		}
+		rank := 0.0
+		if page.Cursor.Rank != nil {
+			rank = *page.Cursor.Rank
+		}
+		cond = fmt.Sprintf("WHERE (rank, created_at, id) %s ($5::float8, $6::timestamptz, $7::uuid)", op)
+		args = append(args, rank, page.Cursor.CreatedAt, page.Cursor.ID)
+	}
+	if page.backward() {
+		order = "rank ASC, created_at ASC, id ASC"
+	}
+
+	// Snippets are only generated for the rows of the requested page,
+	// ts_headline is too expensive to run over every hit. Delimiters already
+	// in the content are dropped so they cannot pose as matches.
+	query := fmt.Sprintf(`
+		WITH q AS (SELECT websearch_to_tsquery($1::regconfig, $2) AS query),
+		hits AS (
+			SELECT 'post' AS type, p.id, p.id AS post_id, p.title, p.content,
+				ts_rank(p.search_vector, q.query)::float8 AS rank, p.created_at
+			FROM posts p, q
+			WHERE p.search_vector @@ q.query AND p.deleted_at IS NULL
+			UNION ALL
+			SELECT 'comment', c.id, c.post_id, p.title, c.content,
+				ts_rank(c.search_vector, q.query)::float8, c.created_at
+			FROM comments c JOIN posts p ON p.id = c.post_id, q
+			WHERE c.search_vector @@ q.query AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+		),
+		page AS (
+			SELECT * FROM hits %s ORDER BY %s LIMIT %d
+		)
+		SELECT type, id, post_id, title, ts_headline($1::regconfig, translate(content, $3, ''), q.query, $4), rank, created_at
+		FROM page, q
+		ORDER BY %s`, cond, order, page.limit()+1, order)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var result models.SearchResult
+		if err := rows.Scan(&result.Type, &result.ID, &result.PostID, &result.Title, &result.Snippet, &result.Rank, &result.CreatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		result.Snippet = markSnippet(result.Snippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	result := buildPage(results, page, searchCursor)
+	if page.WithTotal {
+		total, err := s.count(ctx, `
+			WITH q AS (SELECT websearch_to_tsquery($1::regconfig, $2) AS query)
+			SELECT (SELECT count(*) FROM posts, q WHERE search_vector @@ q.query AND deleted_at IS NULL)
+				+ (SELECT count(*) FROM comments c JOIN posts p ON p.id = c.post_id, q
+					WHERE c.search_vector @@ q.query AND c.deleted_at IS NULL AND p.deleted_at IS NULL)`,
+			s.searchLanguage, text)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
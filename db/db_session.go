@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sessionTouchInterval limits how often last_seen_at is written, so
+// authenticated requests do not all turn into writes.
+const sessionTouchInterval = time.Minute
+
+func (s *PostgresStore) CreateSession(ctx context.Context, userID string, userAgent string, ip string, tokenHash string, expiresAt time.Time) (*models.Session, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	var session models.Session
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `INSERT INTO sessions (user_id, user_agent, ip) VALUES ($1, $2, $3)
+			RETURNING id, user_id, user_agent, ip, created_at, last_seen_at`,
+			userID, userAgent, ip,
+		).Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+			userID, session.ID, tokenHash, expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &session, nil
+}
+
+func (s *PostgresStore) GetSessions(ctx context.Context, userID string) (*[]models.Session, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, mapError(err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	return &sessions, nil
+}
+
+func (s *PostgresStore) TouchSession(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `
+		WITH live AS (
+			SELECT id, last_seen_at FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE sessions SET last_seen_at = now()
+			FROM live
+			WHERE sessions.id = live.id AND live.last_seen_at < now() - $3::interval
+		)
+		SELECT count(*) FROM live`
+
+	found, err := s.count(ctx, query, id, userID, sessionTouchInterval)
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) RevokeSession(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	revoked, err := s.count(ctx, revokeSessionsQuery("id = $1 AND user_id = $2"), id, userID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) RevokeAllSessions(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	revoked, err := s.count(ctx, revokeSessionsQuery("user_id = $1"), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(revoked), nil
+}
+
+// revokeSessionsQuery builds a statement revoking the live sessions matching
+// where together with their refresh tokens. It returns the number of
+// sessions revoked.
+func revokeSessionsQuery(where string) string {
+	return `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = now()
+			WHERE ` + where + ` AND revoked_at IS NULL
+			RETURNING id
+		), tokens AS (
+			UPDATE refresh_tokens SET revoked_at = now()
+			WHERE family_id IN (SELECT id FROM revoked) AND revoked_at IS NULL
+		)
+		SELECT count(*) FROM revoked`
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (*models.RefreshToken, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	var next models.RefreshToken
+	reused := false
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var current models.RefreshToken
+		err := tx.QueryRow(ctx, `SELECT user_id, family_id, expires_at, used_at, revoked_at
+			FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash).Scan(
+			&current.UserID,
+			&current.FamilyID,
+			&current.ExpiresAt,
+			&current.UsedAt,
+			&current.RevokedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil || !current.ExpiresAt.After(time.Now()) {
+			return ErrNotFound
+		}
+		if current.UsedAt != nil {
+			// the revocation must be committed, so it is reported after the transaction
+			reused = true
+			_, err := tx.Exec(ctx, revokeSessionsQuery("id = $1"), current.FamilyID)
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1", tokenHash); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE sessions SET last_seen_at = now() WHERE id = $1", current.FamilyID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, family_id, token_hash, expires_at, created_at`,
+			current.UserID, current.FamilyID, newHash, expiresAt,
+		).Scan(&next.ID, &next.UserID, &next.FamilyID, &next.TokenHash, &next.ExpiresAt, &next.CreatedAt)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if reused {
+		return nil, ErrTokenReused
+	}
+
+	return &next, nil
+}
+
+func (s *PostgresStore) RevokeRefreshFamily(ctx context.Context, tokenHash string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	where := "id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)"
+	revoked, err := s.count(ctx, revokeSessionsQuery(where), tokenHash)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gopher-post/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) GetTOTP(ctx context.Context, userID string) (*models.TOTP, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT user_id, secret, confirmed_at, last_counter, created_at FROM user_totp WHERE user_id = $1"
+
+	var totp models.TOTP
+	err := s.pool.QueryRow(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.ConfirmedAt,
+		&totp.LastCounter,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &totp, nil
+}
+
+func (s *PostgresStore) SetTOTPSecret(ctx context.Context, userID string, secret string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	// a confirmed authenticator is left alone, so no row comes back
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_counter = 0, created_at = now()
+		WHERE user_totp.confirmed_at IS NULL
+		RETURNING user_id`
+
+	var id string
+	err := s.pool.QueryRow(ctx, query, userID, secret).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	return mapError(err)
+}
+
+func (s *PostgresStore) ConfirmTOTP(ctx context.Context, userID string, counter int64, recoveryHashes []string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	return mapError(pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE user_totp SET confirmed_at = now(), last_counter = $2
+			WHERE user_id = $1 AND confirmed_at IS NULL`, userID, counter)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO recovery_codes (user_id, code_hash)
+			SELECT $1::uuid, unnest($2::text[])`, userID, recoveryHashes)
+		return err
+	}))
+}
+
+func (s *PostgresStore) UseTOTPCounter(ctx context.Context, userID string, counter int64) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `UPDATE user_totp SET last_counter = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_counter < $2`
+
+	err := checkAffected(s.pool.Exec(ctx, query, userID, counter))
+	if errors.Is(err, ErrNotFound) {
+		totp, getErr := s.GetTOTP(ctx, userID)
+		if getErr == nil && totp.Enabled() {
+			return ErrTokenReused
+		}
+	}
+	return err
+}
+
+func (s *PostgresStore) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `UPDATE recovery_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	return checkAffected(s.pool.Exec(ctx, query, userID, codeHash))
+}
+
+func (s *PostgresStore) DeleteTOTP(ctx context.Context, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	return mapError(pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}))
+}
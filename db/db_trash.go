@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) GetTrash(ctx context.Context, userID string) (*[]models.TrashItem, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT 'post', id, id, title, content, deleted_at
+		FROM posts WHERE user_id = $1 AND deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'comment', c.id, c.post_id, p.title, c.content, c.deleted_at
+		FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NOT NULL
+		ORDER BY 6 DESC`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		if err := rows.Scan(&item.Type, &item.ID, &item.PostID, &item.Title, &item.Content, &item.DeletedAt); err != nil {
+			return nil, mapError(err)
+		}
+		items = append(items, item)
+	}
+
+	return &items, mapError(rows.Err())
+}
+
+func (s *PostgresStore) RestorePost(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE posts SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL"
+
+	return checkAffected(s.pool.Exec(ctx, query, id, userID))
+}
+
+func (s *PostgresStore) RestoreComment(ctx context.Context, id string, userID string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	// a comment only comes back together with a live post, otherwise it
+	// would stay hidden and be purged with the post anyway
+	query := `WITH target AS (
+			SELECT c.id, p.deleted_at IS NULL AS post_live
+			FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = $1 AND c.user_id = $2 AND c.deleted_at IS NOT NULL
+		), restored AS (
+			UPDATE comments SET deleted_at = NULL
+			FROM target
+			WHERE comments.id = target.id AND target.post_live
+		)
+		SELECT post_live FROM target`
+
+	var postLive bool
+	if err := s.pool.QueryRow(ctx, query, id, userID).Scan(&postLive); err != nil {
+		return mapError(err)
+	}
+	if !postLive {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *PostgresStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	var purged int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "DELETE FROM comments WHERE deleted_at < $1", before)
+		if err != nil {
+			return err
+		}
+		purged += tag.RowsAffected()
+
+		// comments still attached to purged posts go with them through ON DELETE CASCADE
+		tag, err = tx.Exec(ctx, "DELETE FROM posts WHERE deleted_at < $1", before)
+		if err != nil {
+			return err
+		}
+		purged += tag.RowsAffected()
+
+		return nil
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return purged, nil
+}
@@ -1,111 +1,247 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	"gopher-post/models"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func GetUserAll(dbpool *pgxpool.Pool) (*[]models.User, error) {
-	query := "SELECT id, name, email, created_at FROM users"
+func (s *PostgresStore) GetUserAll(ctx context.Context, page PageRequest) (*Page[models.User], error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
 
-	rows, err := dbpool.Query(ctx, query)
+	cond, order, args := page.keyset(1)
+	query := `SELECT id, name, email, role, version, email_verified_at, coalesce(pending_email, ''), disabled_at,
+		magic_link_enabled, created_at FROM users`
+	if cond != "" {
+		query += " WHERE " + cond
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", order, page.limit()+1)
+
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.EmailVerifiedAt, &user.PendingEmail, &user.DisabledAt, &user.MagicLinkEnabled, &user.CreatedAt); err != nil {
+			return nil, mapError(err)
 		}
 		users = append(users, user)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
 
-	return &users, err
+	result := buildPage(users, page, userCursor)
+	if page.WithTotal {
+		total, err := s.count(ctx, "SELECT count(*) FROM users")
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
 }
 
-func GetUserByID(dbpool *pgxpool.Pool, id string) (*models.User, error) {
-	query := "SELECT id, name, email, created_at FROM users WHERE id = $1"
+func (s *PostgresStore) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, name, email, role, version, token_version, email_verified_at, coalesce(pending_email, ''),
+		disabled_at, magic_link_enabled, created_at FROM users WHERE id = $1`
 
 	var user models.User
-	err := dbpool.QueryRow(ctx, query, id).Scan(
+	err := s.pool.QueryRow(ctx, query, id).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
+		&user.Role,
+		&user.Version,
+		&user.TokenVersion,
+		&user.EmailVerifiedAt,
+		&user.PendingEmail,
+		&user.DisabledAt,
+		&user.MagicLinkEnabled,
 		&user.CreatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
-	return &user, err
+	return &user, nil
 }
 
-func GetUserByEmail(dbpool *pgxpool.Pool, email string) (*models.User, error) {
-	query := "SELECT id, name, email, password_hash FROM users WHERE email = $1"
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, name, email, role, password_hash, token_version, email_verified_at, disabled_at,
+		magic_link_enabled FROM users WHERE email = $1`
 
 	var user models.User
-	err := dbpool.QueryRow(ctx, query, email).Scan(
+	err := s.pool.QueryRow(ctx, query, email).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
+		&user.Role,
 		&user.PasswordHash,
+		&user.TokenVersion,
+		&user.EmailVerifiedAt,
+		&user.DisabledAt,
+		&user.MagicLinkEnabled,
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
-	return &user, err
+	return &user, nil
 }
 
-func CheckEmailExists(dbpool *pgxpool.Pool, email string) (bool, error) {
+func (s *PostgresStore) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
 	query := "SELECT id FROM users WHERE email = $1"
 
 	var id string
-	err := dbpool.QueryRow(ctx, query, email).Scan(&id)
+	err := s.pool.QueryRow(ctx, query, email).Scan(&id)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return false, nil
 		}
-		return false, err
+		return false, mapError(err)
 	}
 
 	return true, nil
 }
 
-func CreateUserInDB(dbpool *pgxpool.Pool, name string, email string, passwordHash string) error {
-	query := "INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3)"
+func (s *PostgresStore) CreateUserInDB(ctx context.Context, name string, email string, passwordHash string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, name, email, passwordHash)
-	if err != nil {
-		return err
-	}
+	query := "INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3)"
 
-	return err
+	_, err := s.pool.Exec(ctx, query, name, email, passwordHash)
+	return mapError(err)
 }
 
-func UpdateUserByID(dbpool *pgxpool.Pool, name string, email string, id string) error {
-	query := "UPDATE users SET name = $1, email = $2 WHERE id = $3"
+func (s *PostgresStore) UpdateUserByID(ctx context.Context, name string, email string, id string, ifVersion []int) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	_, err := dbpool.Exec(ctx, query, name, email, id)
+	// a new email is only stored as pending, VerifyEmail applies it
+	query := `UPDATE users SET name = $1,
+		pending_email = CASE WHEN $2 = email THEN NULL ELSE $2 END,
+		version = version + 1
+		WHERE id = $3 AND (coalesce(cardinality($4::int[]), 0) = 0 OR version = ANY ($4::int[]))`
+
+	tag, err := s.pool.Exec(ctx, query, name, email, id, ifVersion)
 	if err != nil {
-		return err
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return s.missingOrStale(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", id, ifVersion)
 	}
 
 	return nil
 }
 
-func DeleteUserByID(dbpool *pgxpool.Pool, id string) error {
+func (s *PostgresStore) DeleteUserByID(ctx context.Context, id string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
 	query := "DELETE FROM users WHERE id = $1"
 
-	_, err := dbpool.Exec(ctx, query, id)
-	if err != nil {
-		return err
+	return checkAffected(s.pool.Exec(ctx, query, id))
+}
+
+func (s *PostgresStore) GetTokenVersion(ctx context.Context, id string) (int, error) {
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT token_version FROM users WHERE id = $1 AND disabled_at IS NULL"
+
+	var version int
+	if err := s.pool.QueryRow(ctx, query, id).Scan(&version); err != nil {
+		return 0, mapError(err)
 	}
 
-	return nil
+	return version, nil
+}
+
+func (s *PostgresStore) UpdatePasswordByID(ctx context.Context, id string, passwordHash string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET password_hash = $1, token_version = token_version + 1 WHERE id = $2"
+
+	return checkAffected(s.pool.Exec(ctx, query, passwordHash, id))
+}
+
+func (s *PostgresStore) RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3"
+
+	return checkAffected(s.pool.Exec(ctx, query, newHash, id, oldHash))
+}
+
+func (s *PostgresStore) SetUserDisabled(ctx context.Context, id string, disabled bool) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET disabled_at = CASE WHEN $1::boolean THEN coalesce(disabled_at, now()) END,
+		token_version = token_version + 1
+		WHERE id = $2`
+
+	return checkAffected(s.pool.Exec(ctx, query, disabled, id))
+}
+
+func (s *PostgresStore) SetMagicLinkEnabled(ctx context.Context, id string, enabled bool) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET magic_link_enabled = $1 WHERE id = $2"
+
+	return checkAffected(s.pool.Exec(ctx, query, enabled, id))
+}
+
+func (s *PostgresStore) SetUserRole(ctx context.Context, id string, role string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET role = $1, token_version = token_version + 1 WHERE id = $2"
+
+	return checkAffected(s.pool.Exec(ctx, query, role, id))
+}
+
+func (s *PostgresStore) SetUserRoleByEmail(ctx context.Context, email string, role string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET role = $1, token_version = token_version + 1 WHERE email = $2"
+
+	return checkAffected(s.pool.Exec(ctx, query, role, email))
+}
+
+func (s *PostgresStore) VerifyEmail(ctx context.Context, id string, email string) error {
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET
+		email_verified_at = CASE WHEN email = $2 THEN coalesce(email_verified_at, now()) ELSE now() END,
+		version = CASE WHEN email = $2 THEN version ELSE version + 1 END,
+		email = $2,
+		pending_email = CASE WHEN pending_email = $2 THEN NULL ELSE pending_email END
+		WHERE id = $1 AND (email = $2 OR pending_email = $2)`
+
+	return checkAffected(s.pool.Exec(ctx, query, id, email))
 }
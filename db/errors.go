@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentinel errors returned by every Store implementation. Callers should
+// compare with errors.Is, the original driver error stays wrapped for logging.
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrConflict  = errors.New("record already exists")
+	ErrInvalidID = errors.New("invalid id")
+	ErrTimeout   = errors.New("query timed out")
+	// ErrVersionMismatch means a conditional update found the row at another
+	// version than the caller expected.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrTokenReused means an already rotated refresh token was presented
+	// again. Its whole family has been revoked by the time it is returned.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgInvalidTextRepresentation = "22P02"
+)
+
+// mapError translates driver errors into the package sentinel errors.
+// A foreign key violation means the referenced row does not exist, so it is
+// reported as ErrNotFound.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case pgForeignKeyViolation:
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		case pgInvalidTextRepresentation:
+			return fmt.Errorf("%w: %w", ErrInvalidID, err)
+		}
+	}
+
+	return err
+}
+
+// checkAffected reports ErrNotFound when an UPDATE or DELETE matched no rows.
+func checkAffected(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
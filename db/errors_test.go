@@ -0,0 +1,22 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapError(t *testing.T) {
+	assert.NoError(t, mapError(nil))
+	assert.ErrorIs(t, mapError(pgx.ErrNoRows), ErrNotFound)
+	assert.ErrorIs(t, mapError(&pgconn.PgError{Code: pgUniqueViolation}), ErrConflict)
+	assert.ErrorIs(t, mapError(&pgconn.PgError{Code: pgForeignKeyViolation}), ErrNotFound)
+	assert.ErrorIs(t, mapError(&pgconn.PgError{Code: pgInvalidTextRepresentation}), ErrInvalidID)
+
+	// error lain harus diteruskan apa adanya
+	other := errors.New("connection reset")
+	assert.Equal(t, other, mapError(other))
+}
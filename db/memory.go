@@ -0,0 +1,1614 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gopher-post/models"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-memory implementation of Store. It is safe for
+// concurrent use and mirrors the behaviour of the Postgres schema, including
+// unique emails and cascading deletes, so it can stand in for a real database
+// in tests and local demos.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	users    map[string]models.User
+	posts    map[string]models.Post
+	comments map[string]models.Comment
+	// revisions holds the history of each post, oldest first.
+	revisions map[string][]models.PostRevision
+	// refreshTokens is keyed by token hash.
+	refreshTokens map[string]models.RefreshToken
+	sessions      map[string]models.Session
+	// passwordResets is keyed by token hash.
+	passwordResets map[string]models.PasswordReset
+	magicLinks     map[string]models.MagicLink
+	totp           map[string]models.TOTP
+	// recoveryCodes maps user id to code hash to the time it was used.
+	recoveryCodes map[string]map[string]*time.Time
+	attempts      map[string]models.LoginAttempt
+	// personalTokens is keyed by token hash.
+	personalTokens map[string]models.PersonalToken
+	// identities is keyed by provider and subject.
+	identities map[identityKey]models.Identity
+	now        func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:          make(map[string]models.User),
+		posts:          make(map[string]models.Post),
+		comments:       make(map[string]models.Comment),
+		revisions:      make(map[string][]models.PostRevision),
+		refreshTokens:  make(map[string]models.RefreshToken),
+		sessions:       make(map[string]models.Session),
+		passwordResets: make(map[string]models.PasswordReset),
+		magicLinks:     make(map[string]models.MagicLink),
+		totp:           make(map[string]models.TOTP),
+		recoveryCodes:  make(map[string]map[string]*time.Time),
+		attempts:       make(map[string]models.LoginAttempt),
+		personalTokens: make(map[string]models.PersonalToken),
+		identities:     make(map[identityKey]models.Identity),
+		now:            time.Now,
+	}
+}
+
+// -- USER --
+
+func (m *MemoryStore) GetUserAll(ctx context.Context, page PageRequest) (*Page[models.User], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]models.User, 0, len(m.users))
+	for _, user := range m.users {
+		user.PasswordHash = ""
+		users = append(users, user)
+	}
+	sortByCreated(users, userCursor)
+
+	return paginateSlice(users, page, userCursor, cursorLess), nil
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user.PasswordHash = ""
+
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.emailTaken(email, ""), nil
+}
+
+func (m *MemoryStore) CreateUserInDB(ctx context.Context, name string, email string, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(email, "") {
+		return ErrConflict
+	}
+
+	id := uuid.NewString()
+	m.users[id] = models.User{
+		ID:               id,
+		Name:             name,
+		Email:            email,
+		Role:             models.RoleUser,
+		PasswordHash:     passwordHash,
+		Version:          1,
+		TokenVersion:     1,
+		MagicLinkEnabled: true,
+		CreatedAt:        m.now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserByID(ctx context.Context, name string, email string, id string, ifVersion []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !versionMatches(user.Version, ifVersion) {
+		return ErrVersionMismatch
+	}
+
+	user.Name = name
+	user.PendingEmail = ""
+	if email != user.Email {
+		user.PendingEmail = email
+	}
+	user.Version++
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) VerifyEmail(ctx context.Context, id string, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	switch email {
+	case user.Email:
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+		}
+	case user.PendingEmail:
+		if m.emailTaken(email, id) {
+			return ErrConflict
+		}
+		user.Email = email
+		user.PendingEmail = ""
+		user.EmailVerifiedAt = &now
+		user.Version++
+	default:
+		return ErrNotFound
+	}
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteUserByID(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.users, id)
+	for postID, post := range m.posts {
+		if post.UserID == id {
+			m.deletePostLocked(postID)
+		}
+	}
+	for commentID, comment := range m.comments {
+		if comment.UserID == id {
+			delete(m.comments, commentID)
+		}
+	}
+	for hash, token := range m.refreshTokens {
+		if token.UserID == id {
+			delete(m.refreshTokens, hash)
+		}
+	}
+	for sessionID, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, sessionID)
+		}
+	}
+	for hash, reset := range m.passwordResets {
+		if reset.UserID == id {
+			delete(m.passwordResets, hash)
+		}
+	}
+	for linkID, link := range m.magicLinks {
+		if link.UserID == id {
+			delete(m.magicLinks, linkID)
+		}
+	}
+	delete(m.totp, id)
+	delete(m.recoveryCodes, id)
+	for hash, token := range m.personalTokens {
+		if token.UserID == id {
+			delete(m.personalTokens, hash)
+		}
+	}
+	for key, identity := range m.identities {
+		if identity.UserID == id {
+			delete(m.identities, key)
+		}
+	}
+	for _, revisions := range m.revisions {
+		for i := range revisions {
+			if revisions[i].EditorID == id {
+				revisions[i].EditorID = ""
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) GetTokenVersion(ctx context.Context, id string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(id); err != nil {
+		return 0, err
+	}
+
+	user, ok := m.users[id]
+	if !ok || user.DisabledAt != nil {
+		return 0, ErrNotFound
+	}
+
+	return user.TokenVersion, nil
+}
+
+func (m *MemoryStore) UpdatePasswordByID(ctx context.Context, id string, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.PasswordHash = passwordHash
+	user.TokenVersion++
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok || user.PasswordHash != oldHash {
+		return ErrNotFound
+	}
+
+	user.PasswordHash = newHash
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) SetUserDisabled(ctx context.Context, id string, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if !disabled {
+		user.DisabledAt = nil
+	} else if user.DisabledAt == nil {
+		now := m.now()
+		user.DisabledAt = &now
+	}
+	user.TokenVersion++
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) SetMagicLinkEnabled(ctx context.Context, id string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.MagicLinkEnabled = enabled
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) SetUserRole(ctx context.Context, id string, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.Role = role
+	user.TokenVersion++
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *MemoryStore) SetUserRoleByEmail(ctx context.Context, email string, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, user := range m.users {
+		if user.Email == email {
+			user.Role = role
+			user.TokenVersion++
+			m.users[id] = user
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+// versionMatches is the in-memory counterpart of the version condition in
+// the conditional UPDATE statements.
+func versionMatches(version int, ifVersion []int) bool {
+	return len(ifVersion) == 0 || slices.Contains(ifVersion, version)
+}
+
+// emailTaken reports whether another user than exceptID owns email. The
+// caller must hold m.mu.
+func (m *MemoryStore) emailTaken(email string, exceptID string) bool {
+	for id, user := range m.users {
+		if id != exceptID && user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// -- POST --
+
+func (m *MemoryStore) GetPostAll(ctx context.Context, page PageRequest) (*Page[models.Post], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := make([]models.Post, 0, len(m.posts))
+	for _, post := range m.posts {
+		if post.DeletedAt == nil {
+			posts = append(posts, post)
+		}
+	}
+	sortByCreated(posts, postCursor)
+
+	return paginateSlice(posts, page, postCursor, cursorLess), nil
+}
+
+func (m *MemoryStore) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	post, ok := m.livePost(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &post, nil
+}
+
+func (m *MemoryStore) GetPostOwnerID(ctx context.Context, id string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(id); err != nil {
+		return "", err
+	}
+
+	post, ok := m.livePost(id)
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return post.UserID, nil
+}
+
+func (m *MemoryStore) CreatePostInDB(ctx context.Context, title string, content string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	id := uuid.NewString()
+	post := models.Post{
+		ID:        id,
+		Title:     title,
+		Content:   content,
+		UserID:    userID,
+		Revision:  1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.posts[id] = post
+	m.addRevisionLocked(post, userID)
+
+	return nil
+}
+
+func (m *MemoryStore) UpdatePostByID(ctx context.Context, title string, content string, id string, editorID string, ifVersion []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	post, ok := m.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if !versionMatches(post.Revision, ifVersion) {
+		return ErrVersionMismatch
+	}
+
+	post.Title = title
+	post.Content = content
+	post.Revision++
+	post.UpdatedAt = m.now()
+	m.posts[id] = post
+	m.addRevisionLocked(post, editorID)
+
+	return nil
+}
+
+func (m *MemoryStore) DeletePostByID(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	post, ok := m.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	post.DeletedAt = &now
+	m.posts[id] = post
+
+	return nil
+}
+
+// livePost returns the post unless it is missing or in the trash. The caller
+// must hold m.mu.
+func (m *MemoryStore) livePost(id string) (models.Post, bool) {
+	post, ok := m.posts[id]
+	if !ok || post.DeletedAt != nil {
+		return models.Post{}, false
+	}
+	return post, true
+}
+
+// deletePostLocked permanently removes a post together with its comments,
+// like the ON DELETE CASCADE in Postgres. The caller must hold m.mu for
+// writing.
+func (m *MemoryStore) deletePostLocked(id string) {
+	delete(m.posts, id)
+	delete(m.revisions, id)
+	for commentID, comment := range m.comments {
+		if comment.PostID == id {
+			delete(m.comments, commentID)
+		}
+	}
+}
+
+// addRevisionLocked records the current state of post. The caller must hold
+// m.mu for writing.
+func (m *MemoryStore) addRevisionLocked(post models.Post, editorID string) {
+	m.revisions[post.ID] = append(m.revisions[post.ID], models.PostRevision{
+		PostID:    post.ID,
+		Revision:  post.Revision,
+		Title:     post.Title,
+		Content:   post.Content,
+		EditorID:  editorID,
+		CreatedAt: post.UpdatedAt,
+	})
+}
+
+// -- REVISION --
+
+func (m *MemoryStore) GetPostRevisions(ctx context.Context, postID string) (*[]models.PostRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(postID); err != nil {
+		return nil, err
+	}
+	if _, ok := m.livePost(postID); !ok {
+		return nil, ErrNotFound
+	}
+
+	revisions := slices.Clone(m.revisions[postID])
+
+	return &revisions, nil
+}
+
+func (m *MemoryStore) GetPostRevision(ctx context.Context, postID string, revision int) (*models.PostRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(postID); err != nil {
+		return nil, err
+	}
+
+	result, ok := m.revisionLocked(postID, revision)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &result, nil
+}
+
+func (m *MemoryStore) RollbackPost(ctx context.Context, postID string, revision int, editorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(postID); err != nil {
+		return err
+	}
+
+	target, ok := m.revisionLocked(postID, revision)
+	if !ok {
+		return ErrNotFound
+	}
+
+	post := m.posts[postID]
+	post.Title = target.Title
+	post.Content = target.Content
+	post.Revision++
+	post.UpdatedAt = m.now()
+	m.posts[postID] = post
+	m.addRevisionLocked(post, editorID)
+
+	return nil
+}
+
+// revisionLocked looks up one revision of a live post. The caller must hold
+// m.mu.
+func (m *MemoryStore) revisionLocked(postID string, revision int) (models.PostRevision, bool) {
+	if _, ok := m.livePost(postID); !ok {
+		return models.PostRevision{}, false
+	}
+	for _, r := range m.revisions[postID] {
+		if r.Revision == revision {
+			return r, true
+		}
+	}
+	return models.PostRevision{}, false
+}
+
+// -- COMMENT --
+
+func (m *MemoryStore) GetCommentByPostID(ctx context.Context, postID string, page PageRequest) (*Page[models.Comment], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(postID); err != nil {
+		return nil, err
+	}
+
+	var comments []models.Comment
+	if _, ok := m.livePost(postID); ok {
+		for _, comment := range m.comments {
+			if comment.PostID == postID && comment.DeletedAt == nil {
+				comments = append(comments, comment)
+			}
+		}
+	}
+	sortByCreated(comments, commentCursor)
+
+	return paginateSlice(comments, page, commentCursor, cursorLess), nil
+}
+
+func (m *MemoryStore) GetCommentOwnerIDs(ctx context.Context, id string) (string, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(id); err != nil {
+		return "", "", err
+	}
+
+	comment, ok := m.comments[id]
+	if !ok || comment.DeletedAt != nil {
+		return "", "", ErrNotFound
+	}
+	post, ok := m.livePost(comment.PostID)
+	if !ok {
+		return "", "", ErrNotFound
+	}
+
+	return comment.UserID, post.UserID, nil
+}
+
+func (m *MemoryStore) CreateCommentInDB(ctx context.Context, comment string, userID string, postID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+	if err := validateID(postID); err != nil {
+		return err
+	}
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.livePost(postID); !ok {
+		return ErrNotFound
+	}
+
+	id := uuid.NewString()
+	m.comments[id] = models.Comment{
+		ID:        id,
+		Content:   comment,
+		UserID:    userID,
+		PostID:    postID,
+		CreatedAt: m.now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteCommentByID(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	comment, ok := m.comments[id]
+	if !ok || comment.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	comment.DeletedAt = &now
+	m.comments[id] = comment
+
+	return nil
+}
+
+// -- TRASH --
+
+func (m *MemoryStore) GetTrash(ctx context.Context, userID string) (*[]models.TrashItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+
+	items := []models.TrashItem{}
+	for _, post := range m.posts {
+		if post.UserID == userID && post.DeletedAt != nil {
+			items = append(items, models.TrashItem{
+				Type:      "post",
+				ID:        post.ID,
+				PostID:    post.ID,
+				Title:     post.Title,
+				Content:   post.Content,
+				DeletedAt: *post.DeletedAt,
+			})
+		}
+	}
+	for _, comment := range m.comments {
+		if comment.UserID == userID && comment.DeletedAt != nil {
+			items = append(items, models.TrashItem{
+				Type:      "comment",
+				ID:        comment.ID,
+				PostID:    comment.PostID,
+				Title:     m.posts[comment.PostID].Title,
+				Content:   comment.Content,
+				DeletedAt: *comment.DeletedAt,
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+
+	return &items, nil
+}
+
+func (m *MemoryStore) RestorePost(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	post, ok := m.posts[id]
+	if !ok || post.UserID != userID || post.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	post.DeletedAt = nil
+	m.posts[id] = post
+
+	return nil
+}
+
+func (m *MemoryStore) RestoreComment(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	comment, ok := m.comments[id]
+	if !ok || comment.UserID != userID || comment.DeletedAt == nil {
+		return ErrNotFound
+	}
+	if _, ok := m.livePost(comment.PostID); !ok {
+		return ErrConflict
+	}
+
+	comment.DeletedAt = nil
+	m.comments[id] = comment
+
+	return nil
+}
+
+func (m *MemoryStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, comment := range m.comments {
+		if comment.DeletedAt != nil && comment.DeletedAt.Before(before) {
+			delete(m.comments, id)
+			purged++
+		}
+	}
+	for id, post := range m.posts {
+		if post.DeletedAt != nil && post.DeletedAt.Before(before) {
+			m.deletePostLocked(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// -- SEARCH --
+
+// Search is a simple stand-in for Postgres full-text search: every term must
+// appear as a whole word, rank counts the matches with title hits weighted
+// double, and snippets mark matched words like ts_headline does.
+func (m *MemoryStore) Search(ctx context.Context, text string, page PageRequest) (*Page[models.SearchResult], error) {
+	terms := searchTerms(text)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []models.SearchResult{}
+	if len(terms) == 0 {
+		return paginateSlice(results, page, searchCursor, searchLess), nil
+	}
+
+	for _, post := range m.posts {
+		if post.DeletedAt != nil {
+			continue
+		}
+		rank := matchRank(terms, post.Title, post.Content)
+		if rank == 0 {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			Type:      "post",
+			ID:        post.ID,
+			PostID:    post.ID,
+			Title:     post.Title,
+			Snippet:   highlight(post.Content, terms),
+			Rank:      rank,
+			CreatedAt: post.CreatedAt,
+		})
+	}
+
+	for _, comment := range m.comments {
+		if _, ok := m.livePost(comment.PostID); !ok || comment.DeletedAt != nil {
+			continue
+		}
+		rank := matchRank(terms, "", comment.Content)
+		if rank == 0 {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			Type:      "comment",
+			ID:        comment.ID,
+			PostID:    comment.PostID,
+			Title:     m.posts[comment.PostID].Title,
+			Snippet:   highlight(comment.Content, terms),
+			Rank:      rank,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return searchLess(searchCursor(results[i]), searchCursor(results[j]))
+	})
+
+	return paginateSlice(results, page, searchCursor, searchLess), nil
+}
+
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchRank returns 0 unless every term occurs in title or content.
+func matchRank(terms []string, title string, content string) float64 {
+	titleWords := searchTerms(title)
+	contentWords := searchTerms(content)
+
+	rank := 0.0
+	for _, term := range terms {
+		hits := 2*countWord(titleWords, term) + countWord(contentWords, term)
+		if hits == 0 {
+			return 0
+		}
+		rank += float64(hits)
+	}
+
+	return rank
+}
+
+func countWord(words []string, term string) int {
+	n := 0
+	for _, word := range words {
+		if word == term {
+			n++
+		}
+	}
+	return n
+}
+
+// highlight wraps matched words in <mark> and trims long content to a window
+// around the first match. Like ts_headline in Search, the content is
+// HTML-escaped.
+func highlight(content string, terms []string) string {
+	const maxSnippet = 200
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+
+	content = strings.NewReplacer(markStart, "", markStop, "").Replace(content)
+	runes := []rune(content)
+	if len(runes) > maxSnippet {
+		start := 0
+		if loc := pattern.FindStringIndex(content); loc != nil {
+			start = max(len([]rune(content[:loc[0]]))-maxSnippet/4, 0)
+		}
+		end := min(start+maxSnippet, len(runes))
+		content = string(runes[start:end])
+	}
+
+	return markSnippet(pattern.ReplaceAllString(content, markStart+"$1"+markStop))
+}
+
+// validateID mirrors Postgres rejecting malformed UUID input.
+func validateID(id string) error {
+	if err := uuid.Validate(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+	return nil
+}
+
+// sortByCreated orders items by (created_at, id), the same order the
+// Postgres listings use.
+func sortByCreated[T any](items []T, key func(T) Cursor) {
+	sort.Slice(items, func(i, j int) bool {
+		return cursorLess(key(items[i]), key(items[j]))
+	})
+}
+
+// -- SESSION --
+
+func (m *MemoryStore) CreateSession(ctx context.Context, userID string, userAgent string, ip string, tokenHash string, expiresAt time.Time) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := m.refreshTokens[tokenHash]; ok {
+		return nil, ErrConflict
+	}
+
+	now := m.now()
+	session := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	m.sessions[session.ID] = session
+	m.refreshTokens[tokenHash] = models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  session.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+
+	return &session, nil
+}
+
+func (m *MemoryStore) GetSessions(ctx context.Context, userID string) (*[]models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := []models.Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b models.Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+
+	return &sessions, nil
+}
+
+func (m *MemoryStore) TouchSession(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+
+	session.LastSeenAt = m.now()
+	m.sessions[id] = session
+
+	return nil
+}
+
+func (m *MemoryStore) RevokeSession(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	m.revokeSessionLocked(id)
+
+	return nil
+}
+
+func (m *MemoryStore) RevokeAllSessions(ctx context.Context, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	for id, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			m.revokeSessionLocked(id)
+			revoked++
+		}
+	}
+
+	return revoked, nil
+}
+
+// revokeSessionLocked revokes a session and its refresh tokens. It reports
+// false when the session was not live. The caller must hold m.mu for
+// writing.
+func (m *MemoryStore) revokeSessionLocked(id string) bool {
+	session, ok := m.sessions[id]
+	if !ok || session.RevokedAt != nil {
+		return false
+	}
+
+	now := m.now()
+	session.RevokedAt = &now
+	m.sessions[id] = session
+
+	for hash, token := range m.refreshTokens {
+		if token.FamilyID == id && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			m.refreshTokens[hash] = token
+		}
+	}
+
+	return true
+}
+
+// -- REFRESH TOKEN --
+
+func (m *MemoryStore) RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (*models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.refreshTokens[tokenHash]
+	if !ok || current.RevokedAt != nil || !current.ExpiresAt.After(m.now()) {
+		return nil, ErrNotFound
+	}
+
+	now := m.now()
+	if current.UsedAt != nil {
+		m.revokeSessionLocked(current.FamilyID)
+		return nil, ErrTokenReused
+	}
+	if _, ok := m.refreshTokens[newHash]; ok {
+		return nil, ErrConflict
+	}
+
+	current.UsedAt = &now
+	m.refreshTokens[tokenHash] = current
+
+	session := m.sessions[current.FamilyID]
+	session.LastSeenAt = now
+	m.sessions[current.FamilyID] = session
+
+	next := models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    current.UserID,
+		FamilyID:  current.FamilyID,
+		TokenHash: newHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	m.refreshTokens[newHash] = next
+
+	return &next, nil
+}
+
+func (m *MemoryStore) RevokeRefreshFamily(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[tokenHash]
+	if !ok || !m.revokeSessionLocked(token.FamilyID) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// -- PASSWORD RESET --
+
+func (m *MemoryStore) CreatePasswordReset(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.passwordResets[tokenHash]; ok {
+		return ErrConflict
+	}
+
+	for hash, reset := range m.passwordResets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			delete(m.passwordResets, hash)
+		}
+	}
+
+	m.passwordResets[tokenHash] = models.PasswordReset{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: m.now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) CheckPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reset, ok := m.passwordResets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(m.now()) {
+		return "", ErrNotFound
+	}
+	if _, ok := m.users[reset.UserID]; !ok {
+		return "", ErrNotFound
+	}
+
+	return reset.UserID, nil
+}
+
+func (m *MemoryStore) ResetPassword(ctx context.Context, tokenHash string, passwordHash string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	reset, ok := m.passwordResets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(now) {
+		return "", ErrNotFound
+	}
+	user, ok := m.users[reset.UserID]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	reset.UsedAt = &now
+	m.passwordResets[tokenHash] = reset
+
+	user.PasswordHash = passwordHash
+	user.TokenVersion++
+	m.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+// -- MAGIC LINK --
+
+func (m *MemoryStore) CreateMagicLink(ctx context.Context, userID string, expiresAt time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return "", err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return "", ErrNotFound
+	}
+
+	for id, link := range m.magicLinks {
+		if link.UserID == userID {
+			delete(m.magicLinks, id)
+		}
+	}
+
+	id := uuid.NewString()
+	m.magicLinks[id] = models.MagicLink{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: m.now(),
+	}
+
+	return id, nil
+}
+
+func (m *MemoryStore) UseMagicLink(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	now := m.now()
+	link, ok := m.magicLinks[id]
+	if !ok || link.UserID != userID || link.UsedAt != nil || !link.ExpiresAt.After(now) {
+		return ErrNotFound
+	}
+
+	link.UsedAt = &now
+	m.magicLinks[id] = link
+
+	return nil
+}
+
+// -- TWO FACTOR --
+
+func (m *MemoryStore) GetTOTP(ctx context.Context, userID string) (*models.TOTP, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+
+	totp, ok := m.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &totp, nil
+}
+
+func (m *MemoryStore) SetTOTPSecret(ctx context.Context, userID string, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if current, ok := m.totp[userID]; ok && current.Enabled() {
+		return ErrConflict
+	}
+
+	m.totp[userID] = models.TOTP{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: m.now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) ConfirmTOTP(ctx context.Context, userID string, counter int64, recoveryHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totp, ok := m.totp[userID]
+	if !ok || totp.Enabled() {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	totp.ConfirmedAt = &now
+	totp.LastCounter = counter
+	m.totp[userID] = totp
+
+	codes := make(map[string]*time.Time, len(recoveryHashes))
+	for _, hash := range recoveryHashes {
+		codes[hash] = nil
+	}
+	m.recoveryCodes[userID] = codes
+
+	return nil
+}
+
+func (m *MemoryStore) UseTOTPCounter(ctx context.Context, userID string, counter int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totp, ok := m.totp[userID]
+	if !ok || !totp.Enabled() {
+		return ErrNotFound
+	}
+	if counter <= totp.LastCounter {
+		return ErrTokenReused
+	}
+
+	totp.LastCounter = counter
+	m.totp[userID] = totp
+
+	return nil
+}
+
+func (m *MemoryStore) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usedAt, ok := m.recoveryCodes[userID][codeHash]
+	if !ok || usedAt != nil {
+		return ErrNotFound
+	}
+
+	now := m.now()
+	m.recoveryCodes[userID][codeHash] = &now
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteTOTP(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+	if _, ok := m.totp[userID]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.totp, userID)
+	delete(m.recoveryCodes, userID)
+
+	return nil
+}
+
+// -- LOGIN ATTEMPT --
+
+func (m *MemoryStore) GetLoginAttempt(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return &models.LoginAttempt{Key: key}, nil
+	}
+	return &attempt, nil
+}
+
+func (m *MemoryStore) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	attempt, ok := m.attempts[key]
+	if !ok || attempt.LastFailureAt.Before(now.Add(-window)) {
+		attempt = models.LoginAttempt{Key: key, LockedUntil: attempt.LockedUntil}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	m.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (m *MemoryStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		attempt = models.LoginAttempt{Key: key, LastFailureAt: m.now()}
+	}
+	attempt.LockedUntil = &until
+	m.attempts[key] = attempt
+
+	return nil
+}
+
+func (m *MemoryStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
+
+func (m *MemoryStore) PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var purged int64
+	for key, attempt := range m.attempts {
+		locked := attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+		if attempt.LastFailureAt.Before(before) && !locked {
+			delete(m.attempts, key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// -- PERSONAL TOKEN --
+
+func (m *MemoryStore) CreatePersonalToken(ctx context.Context, userID string, name string, tokenHash string, scopes []string, expiresAt time.Time) (*models.PersonalToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return nil, err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := m.personalTokens[tokenHash]; ok {
+		return nil, ErrConflict
+	}
+
+	token := models.PersonalToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: m.now(),
+		ExpiresAt: expiresAt,
+	}
+	m.personalTokens[tokenHash] = token
+
+	return &token, nil
+}
+
+func (m *MemoryStore) GetPersonalTokens(ctx context.Context, userID string) (*[]models.PersonalToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	tokens := []models.PersonalToken{}
+	for _, token := range m.personalTokens {
+		if token.UserID == userID && token.RevokedAt == nil && token.ExpiresAt.After(now) {
+			tokens = append(tokens, token)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b models.PersonalToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return &tokens, nil
+}
+
+func (m *MemoryStore) AuthenticatePersonalToken(ctx context.Context, tokenHash string) (*models.PersonalToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	token, ok := m.personalTokens[tokenHash]
+	if !ok || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrNotFound
+	}
+	user, ok := m.users[token.UserID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	result := token
+	if token.LastUsedAt == nil || token.LastUsedAt.Before(now.Add(-sessionTouchInterval)) {
+		token.LastUsedAt = &now
+		m.personalTokens[tokenHash] = token
+	}
+	result.UserRole = user.Role
+
+	return &result, nil
+}
+
+func (m *MemoryStore) RevokePersonalToken(ctx context.Context, id string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	for hash, token := range m.personalTokens {
+		if token.ID == id && token.UserID == userID && token.RevokedAt == nil {
+			now := m.now()
+			token.RevokedAt = &now
+			m.personalTokens[hash] = token
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (m *MemoryStore) RevokeAllPersonalTokens(ctx context.Context, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	now := m.now()
+	for hash, token := range m.personalTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			m.personalTokens[hash] = token
+			revoked++
+		}
+	}
+
+	return revoked, nil
+}
+
+// -- IDENTITY --
+
+type identityKey struct {
+	provider string
+	subject  string
+}
+
+func (m *MemoryStore) GetIdentityUserID(ctx context.Context, provider string, subject string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	identity, ok := m.identities[identityKey{provider, subject}]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return identity.UserID, nil
+}
+
+func (m *MemoryStore) LinkIdentity(ctx context.Context, userID string, provider string, subject string, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateID(userID); err != nil {
+		return err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	key := identityKey{provider, subject}
+	if _, ok := m.identities[key]; ok {
+		return ErrConflict
+	}
+
+	m.identities[key] = models.Identity{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: m.now(),
+	}
+	return nil
+}
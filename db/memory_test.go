@@ -0,0 +1,623 @@
+package db
+
+import (
+	"fmt"
+	"gopher-post/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreUniqueEmail(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	assert.Error(t, store.CreateUserInDB(ctx, "Other", "gopher@example.com", "hash"))
+
+	exists, err := store.CheckEmailExists(ctx, "gopher@example.com")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestMemoryStoreCascadeDelete(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreatePostInDB(ctx, "Judul", "Konten", user.ID))
+	posts, err := store.GetPostAll(ctx, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, posts.Items, 1)
+	postID := posts.Items[0].ID
+
+	require.NoError(t, store.CreateCommentInDB(ctx, "Mantap", user.ID, postID))
+
+	// hapus user harusnya ikut menghapus post dan komentarnya
+	require.NoError(t, store.DeleteUserByID(ctx, user.ID))
+
+	_, err = store.GetPostByID(ctx, postID)
+	assert.Error(t, err)
+
+	comments, err := store.GetCommentByPostID(ctx, postID, PageRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, comments.Items)
+}
+
+func TestMemoryStoreConcurrentWrites(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			email := fmt.Sprintf("user%d@example.com", i)
+			assert.NoError(t, store.CreateUserInDB(ctx, "User", email, "hash"))
+			_, _ = store.GetUserAll(ctx, PageRequest{})
+		}()
+	}
+	wg.Wait()
+
+	users, err := store.GetUserAll(ctx, PageRequest{Limit: MaxPageLimit, WithTotal: true})
+	assert.NoError(t, err)
+	assert.Len(t, users.Items, 50)
+	assert.Equal(t, 50, *users.Total)
+}
+
+func TestMemoryStoreSentinelErrors(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	_, err := store.GetPostByID(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.GetPostByID(ctx, "bukan-uuid")
+	assert.ErrorIs(t, err, ErrInvalidID)
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	err = store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	err = store.DeleteCommentByID(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreSearch(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreatePostInDB(ctx, "Belajar Golang", "Golang itu cepat", user.ID))
+	require.NoError(t, store.CreatePostInDB(ctx, "Resep", "Nasi goreng enak", user.ID))
+	posts, err := store.GetPostAll(ctx, PageRequest{})
+	require.NoError(t, err)
+	require.NoError(t, store.CreateCommentInDB(ctx, "Saya juga suka golang", user.ID, posts.Items[1].ID))
+
+	results, err := store.Search(ctx, "golang", PageRequest{WithTotal: true})
+	require.NoError(t, err)
+	require.Len(t, results.Items, 2)
+	assert.Equal(t, 2, *results.Total)
+
+	// judul yang cocok harus lebih relevan dari komentar
+	assert.Equal(t, "post", results.Items[0].Type)
+	assert.Equal(t, "Golang itu <mark>cepat</mark>", highlight("Golang itu cepat", []string{"cepat"}))
+	assert.Contains(t, results.Items[1].Snippet, "<mark>golang</mark>")
+	assert.Equal(t, "Resep", results.Items[1].Title)
+
+	results, err = store.Search(ctx, "golang enak", PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, results.Items)
+}
+
+func TestSearchSnippetEscapesHTML(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	require.NoError(t, store.CreatePostInDB(ctx, "Jahat", "Golang <script>alert(1)</script> <img src=x onerror=\"alert(2)\"> \x02golang", user.ID))
+
+	results, err := store.Search(ctx, "golang", PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, results.Items, 1)
+
+	// konten post hanya boleh muncul sebagai teks, satu-satunya tag adalah <mark>
+	snippet := results.Items[0].Snippet
+	assert.NotContains(t, snippet, "<script>")
+	assert.NotContains(t, snippet, "<img")
+	assert.Contains(t, snippet, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.Equal(t, 2, strings.Count(snippet, "<mark>"))
+	assert.Equal(t, 2, strings.Count(snippet, "</mark>"))
+
+	// snippet dari ts_headline melewati escape yang sama
+	assert.Equal(t, "&lt;b&gt;<mark>Go</mark>&lt;/b&gt;", markSnippet("<b>"+markStart+"Go"+markStop+"</b>"))
+}
+
+func TestMemoryStoreTrash(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreatePostInDB(ctx, "Judul", "Konten", user.ID))
+	posts, err := store.GetPostAll(ctx, PageRequest{})
+	require.NoError(t, err)
+	postID := posts.Items[0].ID
+	require.NoError(t, store.CreateCommentInDB(ctx, "Komentar", user.ID, postID))
+	created, err := store.GetCommentByPostID(ctx, postID, PageRequest{})
+	require.NoError(t, err)
+	commentID := created.Items[0].ID
+
+	require.NoError(t, store.DeletePostByID(ctx, postID))
+	assert.ErrorIs(t, store.DeletePostByID(ctx, postID), ErrNotFound)
+
+	_, err = store.GetPostByID(ctx, postID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.CreateCommentInDB(ctx, "Telat", user.ID, postID), ErrNotFound)
+
+	// komentar post di tempat sampah ikut tersembunyi, termasuk dari total
+	hidden, err := store.GetCommentByPostID(ctx, postID, PageRequest{WithTotal: true})
+	require.NoError(t, err)
+	assert.Empty(t, hidden.Items)
+	require.NotNil(t, hidden.Total)
+	assert.Zero(t, *hidden.Total)
+
+	trash, err := store.GetTrash(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, *trash, 1)
+	assert.Equal(t, "post", (*trash)[0].Type)
+
+	// komentar tidak bisa dipulihkan selama post-nya masih di tempat sampah
+	require.NoError(t, store.DeleteCommentByID(ctx, commentID))
+	assert.ErrorIs(t, store.RestoreComment(ctx, commentID, user.ID), ErrConflict)
+
+	// restore harus mengembalikan komentar yang ikut tersembunyi
+	require.NoError(t, store.RestorePost(ctx, postID, user.ID))
+	require.NoError(t, store.RestoreComment(ctx, commentID, user.ID))
+	comments, err := store.GetCommentByPostID(ctx, postID, PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, comments.Items, 1)
+
+	require.NoError(t, store.DeletePostByID(ctx, postID))
+	purged, err := store.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged, "item yang belum lewat masa retensi tidak boleh di-purge")
+
+	purged, err = store.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.ErrorIs(t, store.RestorePost(ctx, postID, user.ID), ErrNotFound)
+}
+
+func TestMemoryStoreRevisions(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreatePostInDB(ctx, "Judul", "Konten", user.ID))
+	posts, err := store.GetPostAll(ctx, PageRequest{})
+	require.NoError(t, err)
+	postID := posts.Items[0].ID
+
+	require.NoError(t, store.UpdatePostByID(ctx, "Judul baru", "Konten baru", postID, user.ID, nil))
+	post, err := store.GetPostByID(ctx, postID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, post.Revision)
+	assert.ErrorIs(t, store.UpdatePostByID(ctx, "Basi", "Basi", postID, user.ID, []int{1}), ErrVersionMismatch)
+
+	require.NoError(t, store.RollbackPost(ctx, postID, 1, user.ID))
+	post, err = store.GetPostByID(ctx, postID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, post.Revision)
+	assert.Equal(t, "Judul", post.Title)
+
+	revisions, err := store.GetPostRevisions(ctx, postID)
+	require.NoError(t, err)
+	require.Len(t, *revisions, 3)
+	assert.Equal(t, "Konten baru", (*revisions)[1].Content)
+	assert.Equal(t, user.ID, (*revisions)[2].EditorID)
+
+	_, err = store.GetPostRevision(ctx, postID, 9)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.RollbackPost(ctx, postID, 9, user.ID), ErrNotFound)
+
+	// revisi post yang ada di trash ikut tersembunyi
+	require.NoError(t, store.DeletePostByID(ctx, postID))
+	_, err = store.GetPostRevisions(ctx, postID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreRefreshTokenRotation(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	session, err := store.CreateSession(ctx, user.ID, "curl/8.0", "127.0.0.1", "hash-1", expires)
+	require.NoError(t, err)
+
+	second, err := store.RotateRefreshToken(ctx, "hash-1", "hash-2", expires)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, second.FamilyID)
+	assert.Equal(t, user.ID, second.UserID)
+
+	// token lama dipakai ulang: seluruh family dicabut
+	_, err = store.RotateRefreshToken(ctx, "hash-1", "hash-3", expires)
+	assert.ErrorIs(t, err, ErrTokenReused)
+	_, err = store.RotateRefreshToken(ctx, "hash-2", "hash-3", expires)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.TouchSession(ctx, session.ID, user.ID), ErrNotFound)
+
+	_, err = store.CreateSession(ctx, user.ID, "", "", "hash-expired", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = store.RotateRefreshToken(ctx, "hash-expired", "hash-4", expires)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.CreateSession(ctx, user.ID, "", "", "hash-5", expires)
+	require.NoError(t, err)
+	require.NoError(t, store.RevokeRefreshFamily(ctx, "hash-5"))
+	assert.ErrorIs(t, store.RevokeRefreshFamily(ctx, "hash-5"), ErrNotFound)
+}
+
+func TestMemoryStoreSessions(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	laptop, err := store.CreateSession(ctx, user.ID, "laptop", "10.0.0.1", "hash-laptop", expires)
+	require.NoError(t, err)
+	_, err = store.CreateSession(ctx, user.ID, "phone", "10.0.0.2", "hash-phone", expires)
+	require.NoError(t, err)
+
+	sessions, err := store.GetSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, *sessions, 2)
+
+	// laptop hilang: cabut sesinya beserta refresh token-nya
+	require.NoError(t, store.RevokeSession(ctx, laptop.ID, user.ID))
+	assert.ErrorIs(t, store.TouchSession(ctx, laptop.ID, user.ID), ErrNotFound)
+	_, err = store.RotateRefreshToken(ctx, "hash-laptop", "hash-next", expires)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.RevokeSession(ctx, laptop.ID, user.ID), ErrNotFound)
+
+	revoked, err := store.RevokeAllSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+
+	sessions, err = store.GetSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, *sessions)
+}
+
+func TestMemoryStoreTokenVersion(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	version, err := store.GetTokenVersion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	require.NoError(t, store.UpdatePasswordByID(ctx, user.ID, "hash-baru"))
+	version, err = store.GetTokenVersion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	require.NoError(t, store.SetUserDisabled(ctx, user.ID, true))
+	_, err = store.GetTokenVersion(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.SetUserDisabled(ctx, user.ID, false))
+	version, err = store.GetTokenVersion(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+}
+
+func TestMemoryStoreSetUserRole(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, user.Role)
+
+	require.NoError(t, store.SetUserRoleByEmail(ctx, "gopher@example.com", models.RoleModerator))
+	user, err = store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, user.Role)
+	// ganti role harus membatalkan token lama
+	assert.Equal(t, 2, user.TokenVersion)
+
+	assert.ErrorIs(t, store.SetUserRoleByEmail(ctx, "tidakada@example.com", models.RoleAdmin), ErrNotFound)
+	assert.ErrorIs(t, store.SetUserRole(ctx, "00000000-0000-0000-0000-000000000000", models.RoleAdmin), ErrNotFound)
+}
+
+func TestMemoryStorePasswordReset(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	require.NoError(t, store.CreatePasswordReset(ctx, user.ID, "lama", expires))
+	// token baru menggantikan token yang belum dipakai
+	require.NoError(t, store.CreatePasswordReset(ctx, user.ID, "baru", expires))
+	_, err = store.ResetPassword(ctx, "lama", "hash-baru")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// cek token tidak memakainya
+	userID, err := store.CheckPasswordReset(ctx, "baru")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	_, err = store.CheckPasswordReset(ctx, "lama")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	userID, err = store.ResetPassword(ctx, "baru", "hash-baru")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	_, err = store.CheckPasswordReset(ctx, "baru")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	user, err = store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "hash-baru", user.PasswordHash)
+	assert.Equal(t, 2, user.TokenVersion)
+
+	_, err = store.ResetPassword(ctx, "baru", "hash-lain")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.CreatePasswordReset(ctx, user.ID, "kedaluwarsa", time.Now().Add(-time.Minute)))
+	_, err = store.ResetPassword(ctx, "kedaluwarsa", "hash-lain")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreVerifyEmail(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	require.NoError(t, store.CreateUserInDB(ctx, "Lain", "lain@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	require.NoError(t, store.VerifyEmail(ctx, user.ID, "gopher@example.com"))
+	assert.ErrorIs(t, store.VerifyEmail(ctx, user.ID, "asing@example.com"), ErrNotFound)
+
+	// email baru hanya disimpan sebagai pending
+	require.NoError(t, store.UpdateUserByID(ctx, "Gopher", "baru@example.com", user.ID, nil))
+	user, err = store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "gopher@example.com", user.Email)
+	assert.Equal(t, "baru@example.com", user.PendingEmail)
+
+	require.NoError(t, store.VerifyEmail(ctx, user.ID, "baru@example.com"))
+	user, err = store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "baru@example.com", user.Email)
+	assert.Empty(t, user.PendingEmail)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// alamat yang sudah dipakai user lain saat konfirmasi
+	require.NoError(t, store.UpdateUserByID(ctx, "Gopher", "lain@example.com", user.ID, nil))
+	assert.ErrorIs(t, store.VerifyEmail(ctx, user.ID, "lain@example.com"), ErrConflict)
+}
+
+func TestMemoryStoreTwoFactor(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	_, err = store.GetTOTP(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.SetTOTPSecret(ctx, user.ID, "SECRET"))
+	assert.ErrorIs(t, store.UseTOTPCounter(ctx, user.ID, 10), ErrNotFound)
+	require.NoError(t, store.ConfirmTOTP(ctx, user.ID, 10, []string{"kode-1", "kode-2"}))
+
+	totp, err := store.GetTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, totp.Enabled())
+	// secret tidak bisa diganti selama 2FA aktif
+	assert.ErrorIs(t, store.SetTOTPSecret(ctx, user.ID, "LAIN"), ErrConflict)
+
+	assert.ErrorIs(t, store.UseTOTPCounter(ctx, user.ID, 10), ErrTokenReused)
+	require.NoError(t, store.UseTOTPCounter(ctx, user.ID, 11))
+
+	require.NoError(t, store.UseRecoveryCode(ctx, user.ID, "kode-1"))
+	assert.ErrorIs(t, store.UseRecoveryCode(ctx, user.ID, "kode-1"), ErrNotFound)
+	assert.ErrorIs(t, store.UseRecoveryCode(ctx, user.ID, "asing"), ErrNotFound)
+
+	require.NoError(t, store.DeleteTOTP(ctx, user.ID))
+	assert.ErrorIs(t, store.UseRecoveryCode(ctx, user.ID, "kode-2"), ErrNotFound)
+	_, err = store.GetTOTP(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreLoginAttempts(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	attempt, err := store.GetLoginAttempt(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, attempt.Failures)
+
+	for range 3 {
+		attempt, err = store.RecordLoginFailure(ctx, "account:gopher@example.com", time.Minute)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, attempt.Failures)
+
+	// kegagalan di luar window mulai dihitung dari awal
+	attempt, err = store.RecordLoginFailure(ctx, "account:gopher@example.com", -time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, store.LockLogin(ctx, "ip:192.0.2.1", until))
+	require.NoError(t, store.ResetLoginAttempts(ctx, "account:gopher@example.com"))
+	attempt, err = store.GetLoginAttempt(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, attempt.Failures)
+
+	_, err = store.RecordLoginFailure(ctx, "account:other@example.com", time.Minute)
+	require.NoError(t, err)
+
+	// key yang masih terkunci tidak ikut dihapus
+	purged, err := store.PurgeLoginAttempts(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+	attempt, err = store.GetLoginAttempt(ctx, "ip:192.0.2.1")
+	require.NoError(t, err)
+	require.NotNil(t, attempt.LockedUntil)
+	assert.WithinDuration(t, until, *attempt.LockedUntil, time.Second)
+}
+
+func TestMemoryStoreRehashPassword(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash-lama"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	// hash yang sudah berubah tidak boleh ditimpa
+	assert.ErrorIs(t, store.RehashPassword(ctx, user.ID, "hash-lain", "hash-baru"), ErrNotFound)
+	require.NoError(t, store.RehashPassword(ctx, user.ID, "hash-lama", "hash-baru"))
+
+	rehashed, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "hash-baru", rehashed.PasswordHash)
+	assert.Equal(t, user.TokenVersion, rehashed.TokenVersion)
+}
+
+func TestMemoryStorePersonalTokens(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	token, err := store.CreatePersonalToken(ctx, user.ID, "CI", "hash-1", []string{models.ScopePostsWrite}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = store.CreatePersonalToken(ctx, user.ID, "Lama", "hash-2", []string{models.ScopePostsWrite}, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+
+	// token kedaluwarsa tidak ikut terdaftar dan tidak bisa dipakai
+	tokens, err := store.GetPersonalTokens(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, *tokens, 1)
+	_, err = store.AuthenticatePersonalToken(ctx, "hash-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	authenticated, err := store.AuthenticatePersonalToken(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.UserID)
+	assert.Equal(t, models.RoleUser, authenticated.UserRole)
+
+	assert.ErrorIs(t, store.RevokePersonalToken(ctx, token.ID, "00000000-0000-0000-0000-000000000000"), ErrNotFound)
+	require.NoError(t, store.RevokePersonalToken(ctx, token.ID, user.ID))
+	assert.ErrorIs(t, store.RevokePersonalToken(ctx, token.ID, user.ID), ErrNotFound)
+	_, err = store.AuthenticatePersonalToken(ctx, "hash-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// token kedaluwarsa yang belum dicabut ikut dihitung, yang sudah dicabut tidak
+	_, err = store.CreatePersonalToken(ctx, user.ID, "Bot", "hash-3", []string{models.ScopePostsWrite}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	revoked, err := store.RevokeAllPersonalTokens(ctx, user.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, revoked)
+	_, err = store.AuthenticatePersonalToken(ctx, "hash-3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreIdentities(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+
+	_, err = store.GetIdentityUserID(ctx, "google", "123")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.LinkIdentity(ctx, user.ID, "google", "123", "gopher@example.com"))
+	userID, err := store.GetIdentityUserID(ctx, "google", "123")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+
+	// subject yang sama di provider lain adalah akun yang berbeda
+	_, err = store.GetIdentityUserID(ctx, "gitlab", "123")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.LinkIdentity(ctx, user.ID, "google", "123", "gopher@example.com"), ErrConflict)
+
+	require.NoError(t, store.DeleteUserByID(ctx, user.ID))
+	_, err = store.GetIdentityUserID(ctx, "google", "123")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreMagicLinks(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.CreateUserInDB(ctx, "Gopher", "gopher@example.com", "hash"))
+	user, err := store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.True(t, user.MagicLinkEnabled)
+
+	first, err := store.CreateMagicLink(ctx, user.ID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	second, err := store.CreateMagicLink(ctx, user.ID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	// link baru menggantikan yang lama, dan hanya bisa dipakai sekali oleh pemiliknya
+	assert.ErrorIs(t, store.UseMagicLink(ctx, first, user.ID), ErrNotFound)
+	assert.ErrorIs(t, store.UseMagicLink(ctx, second, "00000000-0000-0000-0000-000000000000"), ErrNotFound)
+	require.NoError(t, store.UseMagicLink(ctx, second, user.ID))
+	assert.ErrorIs(t, store.UseMagicLink(ctx, second, user.ID), ErrNotFound)
+
+	expired, err := store.CreateMagicLink(ctx, user.ID, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.UseMagicLink(ctx, expired, user.ID), ErrNotFound)
+
+	require.NoError(t, store.SetMagicLinkEnabled(ctx, user.ID, false))
+	user, err = store.GetUserByEmail(ctx, "gopher@example.com")
+	require.NoError(t, err)
+	assert.False(t, user.MagicLinkEnabled)
+}
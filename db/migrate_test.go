@@ -0,0 +1,46 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := LoadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versi migrasi harus berurutan tanpa celah")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoadMigrationsRequiresPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_init.up.sql": {Data: []byte("SELECT 1")},
+	}
+
+	_, err := loadMigrations(fsys, ".")
+	assert.Error(t, err)
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000001_init.up.sql"), []byte("SELECT 1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000001_init.down.sql"), []byte("SELECT 1"), 0o644))
+
+	upPath, downPath, err := CreateMigration(dir, "Add Post Tags")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000002_add_post_tags.up.sql"), upPath)
+	assert.Equal(t, filepath.Join(dir, "000002_add_post_tags.down.sql"), downPath)
+
+	_, err = loadMigrations(os.DirFS(dir), ".")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,15 @@
+-- search_config records the text search configuration a row was indexed
+-- with, so changing SEARCH_LANGUAGE only affects rows written afterwards.
+ALTER TABLE posts ADD COLUMN search_config REGCONFIG NOT NULL DEFAULT 'simple';
+ALTER TABLE posts ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_config, coalesce(title, '')), 'A') ||
+    setweight(to_tsvector(search_config, coalesce(content, '')), 'B')
+) STORED;
+
+ALTER TABLE comments ADD COLUMN search_config REGCONFIG NOT NULL DEFAULT 'simple';
+ALTER TABLE comments ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector(search_config, coalesce(content, ''))
+) STORED;
+
+CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector);
+CREATE INDEX idx_comments_search_vector ON comments USING GIN (search_vector);
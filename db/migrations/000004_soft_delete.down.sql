@@ -0,0 +1,9 @@
+-- rows in the trash would reappear once the column is gone, remove them first
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_comments_trash;
+DROP INDEX IF EXISTS idx_posts_trash;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- partial indexes keep the trash listing and the purge job cheap
+CREATE INDEX idx_posts_trash ON posts (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_comments_trash ON comments (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
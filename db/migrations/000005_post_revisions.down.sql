@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS post_revisions;
+DROP FUNCTION IF EXISTS post_revisions_immutable();
+
+ALTER TABLE posts DROP COLUMN IF EXISTS revision;
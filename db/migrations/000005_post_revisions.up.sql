@@ -0,0 +1,33 @@
+-- revision is the number of the latest row in post_revisions for the post.
+-- It is bumped by the same UPDATE that changes the post, so concurrent edits
+-- are serialised by the row lock.
+ALTER TABLE posts ADD COLUMN revision INT NOT NULL DEFAULT 1;
+
+CREATE TABLE post_revisions (
+    post_id    UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    revision   INT NOT NULL,
+    title      TEXT NOT NULL,
+    content    TEXT NOT NULL,
+    editor_id  UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (post_id, revision)
+);
+
+-- revisions are an audit trail, only ON DELETE SET NULL of the editor may touch them
+CREATE FUNCTION post_revisions_immutable() RETURNS trigger AS $$
+BEGIN
+    IF NEW.post_id = OLD.post_id AND NEW.revision = OLD.revision AND NEW.title = OLD.title
+        AND NEW.content = OLD.content AND NEW.created_at = OLD.created_at AND NEW.editor_id IS NULL THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'post revisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_post_revisions_immutable
+    BEFORE UPDATE ON post_revisions
+    FOR EACH ROW EXECUTE FUNCTION post_revisions_immutable();
+
+-- existing posts start their history at revision 1
+INSERT INTO post_revisions (post_id, revision, title, content, editor_id, created_at)
+SELECT id, 1, title, content, user_id, updated_at FROM posts;
@@ -0,0 +1,2 @@
+-- version is bumped by every update and exposed as the user's ETag
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
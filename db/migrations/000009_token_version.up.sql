@@ -0,0 +1,5 @@
+-- token_version is embedded in access tokens ("tv" claim). Bumping it, e.g.
+-- on a password change, invalidates every token issued before.
+ALTER TABLE users
+    ADD COLUMN token_version INT NOT NULL DEFAULT 1,
+    ADD COLUMN disabled_at   TIMESTAMPTZ;
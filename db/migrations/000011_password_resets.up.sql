@@ -0,0 +1,11 @@
+-- Password reset tokens are stored as SHA-256 hashes and can be used once.
+CREATE TABLE password_resets (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
@@ -0,0 +1,7 @@
+-- New accounts start unverified. An email change is kept in pending_email
+-- until the new address confirms it. Existing accounts count as verified.
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMPTZ,
+    ADD COLUMN pending_email     TEXT;
+
+UPDATE users SET email_verified_at = created_at;
@@ -0,0 +1,19 @@
+-- One TOTP authenticator per user. It only counts once confirmed_at is set;
+-- last_counter keeps a code from being used twice.
+CREATE TABLE user_totp (
+    user_id      UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret       TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Recovery codes are stored as SHA-256 hashes and can be used once.
+CREATE TABLE recovery_codes (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, code_hash)
+);
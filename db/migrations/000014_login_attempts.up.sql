@@ -0,0 +1,10 @@
+-- Failed logins per account and per client IP. key is "account:<email>" or
+-- "ip:<address>"; rows are cleared on success and purged once stale.
+CREATE TABLE login_attempts (
+    key             TEXT PRIMARY KEY,
+    failures        INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts (last_failure_at);
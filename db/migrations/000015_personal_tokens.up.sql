@@ -0,0 +1,15 @@
+-- Personal access tokens let scripts act as a user without their password.
+-- Only the SHA-256 hash of the token is stored; scopes limit what it may do.
+CREATE TABLE personal_tokens (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    scopes       TEXT[] NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_personal_tokens_user_id ON personal_tokens (user_id) WHERE revoked_at IS NULL;
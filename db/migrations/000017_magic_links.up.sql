@@ -0,0 +1,14 @@
+-- Users may log in with a link mailed to them unless they opt out.
+ALTER TABLE users ADD COLUMN magic_link_enabled BOOLEAN NOT NULL DEFAULT true;
+
+-- A magic link is a signed token naming one of these rows, which makes it
+-- usable once. Each user has at most one outstanding link.
+CREATE TABLE magic_links (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_magic_links_user_id ON magic_links (user_id);
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-post/models"
+	"slices"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 10
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a listing ordered by (created_at, id). Clients
+// only ever see it encoded by EncodeCursor.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	// Rank is only set for listings ordered by relevance, such as search.
+	Rank *float64 `json:"r,omitempty"`
+	// Backward asks for the page that ends right before this position.
+	Backward bool `json:"b,omitempty"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// PageRequest describes which slice of a listing to return. A nil Cursor
+// means the first page.
+type PageRequest struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+func (p PageRequest) limit() int {
+	if p.Limit < 1 {
+		return DefaultPageLimit
+	}
+	return min(p.Limit, MaxPageLimit)
+}
+
+func (p PageRequest) backward() bool {
+	return p.Cursor != nil && p.Cursor.Backward
+}
+
+// keyset returns the SQL condition and ORDER BY clause for the request.
+// The condition is empty for the first page; otherwise it references the
+// placeholders $argPos and $argPos+1 whose values are returned in args.
+func (p PageRequest) keyset(argPos int) (cond string, order string, args []any) {
+	if p.backward() {
+		order = "created_at DESC, id DESC"
+	} else {
+		order = "created_at ASC, id ASC"
+	}
+
+	if p.Cursor == nil {
+		return "", order, nil
+	}
+
+	op := ">"
+	if p.backward() {
+		op = "<"
+	}
+	cond = fmt.Sprintf("(created_at, id) %s ($%d, $%d)", op, argPos, argPos+1)
+
+	return cond, order, []any{p.Cursor.CreatedAt, p.Cursor.ID}
+}
+
+// Page is one slice of a listing in ascending cursor order.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	PrevCursor string
+	// Total is only filled when PageRequest.WithTotal is set.
+	Total *int
+}
+
+// buildPage turns up to limit+1 rows fetched in the request's direction into
+// a Page in ascending order. The extra row only signals that more rows exist
+// in that direction.
+func buildPage[T any](rows []T, req PageRequest, key func(T) Cursor) *Page[T] {
+	limit := req.limit()
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if req.backward() {
+		slices.Reverse(rows)
+	}
+
+	page := &Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	var first, last *Cursor
+	if len(rows) > 0 {
+		f, l := key(rows[0]), key(rows[len(rows)-1])
+		first, last = &f, &l
+	} else if req.Cursor != nil {
+		// empty page past either end: point back to where the client came from
+		first, last = req.Cursor, req.Cursor
+	}
+
+	if req.backward() {
+		if hasMore {
+			page.PrevCursor = encodeDirection(*first, true)
+		}
+		if last != nil {
+			page.NextCursor = encodeDirection(*last, false)
+		}
+	} else {
+		if hasMore {
+			page.NextCursor = encodeDirection(*last, false)
+		}
+		if req.Cursor != nil && first != nil {
+			page.PrevCursor = encodeDirection(*first, true)
+		}
+	}
+
+	return page
+}
+
+func encodeDirection(c Cursor, backward bool) string {
+	c.Backward = backward
+	return EncodeCursor(c)
+}
+
+// paginateSlice applies req to items already sorted by less. It is the
+// in-memory counterpart of the keyset queries.
+func paginateSlice[T any](items []T, req PageRequest, key func(T) Cursor, less func(a, b Cursor) bool) *Page[T] {
+	var rows []T
+	limit := req.limit()
+
+	if req.backward() {
+		for i := len(items) - 1; i >= 0 && len(rows) <= limit; i-- {
+			if less(key(items[i]), *req.Cursor) {
+				rows = append(rows, items[i])
+			}
+		}
+	} else {
+		for i := 0; i < len(items) && len(rows) <= limit; i++ {
+			if req.Cursor == nil || less(*req.Cursor, key(items[i])) {
+				rows = append(rows, items[i])
+			}
+		}
+	}
+
+	page := buildPage(rows, req, key)
+	if req.WithTotal {
+		total := len(items)
+		page.Total = &total
+	}
+
+	return page
+}
+
+// cursorLess is the (created_at, id) ascending order used by listings.
+func cursorLess(a, b Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func postCursor(p models.Post) Cursor       { return Cursor{CreatedAt: p.CreatedAt, ID: p.ID} }
+func commentCursor(c models.Comment) Cursor { return Cursor{CreatedAt: c.CreatedAt, ID: c.ID} }
+func userCursor(u models.User) Cursor       { return Cursor{CreatedAt: u.CreatedAt, ID: u.ID} }
+
+func searchCursor(r models.SearchResult) Cursor {
+	rank := r.Rank
+	return Cursor{CreatedAt: r.CreatedAt, ID: r.ID, Rank: &rank}
+}
+
+// searchLess is the relevance order used by search: highest rank first, then
+// newest first.
+func searchLess(a, b Cursor) bool {
+	var ra, rb float64
+	if a.Rank != nil {
+		ra = *a.Rank
+	}
+	if b.Rank != nil {
+		rb = *b.Rank
+	}
+	if ra != rb {
+		return ra > rb
+	}
+	return cursorLess(b, a)
+}
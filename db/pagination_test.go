@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC), ID: "abc", Backward: true}
+
+	decoded, err := DecodeCursor(EncodeCursor(c))
+	require.NoError(t, err)
+	assert.True(t, c.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, c.ID, decoded.ID)
+	assert.True(t, decoded.Backward)
+
+	_, err = DecodeCursor("bukan-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestPaginateSliceWalksBothDirections(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []Cursor
+	for i := range 7 {
+		// dua item berbagi created_at yang sama untuk menguji tie-breaker id
+		items = append(items, Cursor{CreatedAt: base.Add(time.Duration(i/2) * time.Second), ID: fmt.Sprintf("id-%d", i)})
+	}
+	key := func(c Cursor) Cursor { return c }
+	ids := func(page *Page[Cursor]) []string {
+		var out []string
+		for _, c := range page.Items {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+
+	first := paginateSlice(items, PageRequest{Limit: 3}, key, cursorLess)
+	assert.Equal(t, []string{"id-0", "id-1", "id-2"}, ids(first))
+	assert.Empty(t, first.PrevCursor)
+	require.NotEmpty(t, first.NextCursor)
+
+	cursor, err := DecodeCursor(first.NextCursor)
+	require.NoError(t, err)
+	second := paginateSlice(items, PageRequest{Limit: 3, Cursor: cursor}, key, cursorLess)
+	assert.Equal(t, []string{"id-3", "id-4", "id-5"}, ids(second))
+
+	cursor, err = DecodeCursor(second.NextCursor)
+	require.NoError(t, err)
+	last := paginateSlice(items, PageRequest{Limit: 3, Cursor: cursor}, key, cursorLess)
+	assert.Equal(t, []string{"id-6"}, ids(last))
+	assert.Empty(t, last.NextCursor)
+
+	// kembali ke halaman sebelumnya lewat prev_cursor
+	cursor, err = DecodeCursor(last.PrevCursor)
+	require.NoError(t, err)
+	back := paginateSlice(items, PageRequest{Limit: 3, Cursor: cursor}, key, cursorLess)
+	assert.Equal(t, []string{"id-3", "id-4", "id-5"}, ids(back))
+
+	cursor, err = DecodeCursor(back.PrevCursor)
+	require.NoError(t, err)
+	front := paginateSlice(items, PageRequest{Limit: 3, Cursor: cursor}, key, cursorLess)
+	assert.Equal(t, []string{"id-0", "id-1", "id-2"}, ids(front))
+	assert.Empty(t, front.PrevCursor)
+	assert.NotEmpty(t, front.NextCursor)
+}
+
+func TestKeysetClause(t *testing.T) {
+	cond, order, args := PageRequest{}.keyset(1)
+	assert.Empty(t, cond)
+	assert.Equal(t, "created_at ASC, id ASC", order)
+	assert.Empty(t, args)
+
+	cond, order, args = PageRequest{Cursor: &Cursor{ID: "x", Backward: true}}.keyset(2)
+	assert.Equal(t, "(created_at, id) < ($2, $3)", cond)
+	assert.Equal(t, "created_at DESC, id DESC", order)
+	assert.Len(t, args, 2)
+}
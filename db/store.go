@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"gopher-post/models"
+	"time"
+)
+
+// UserStore abstracts persistence of users so handlers do not depend on a
+// concrete database driver.
+type UserStore interface {
+	GetUserAll(ctx context.Context, page PageRequest) (*Page[models.User], error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	CheckEmailExists(ctx context.Context, email string) (bool, error)
+	CreateUserInDB(ctx context.Context, name string, email string, passwordHash string) error
+	// UpdateUserByID changes the user and bumps its version. A different
+	// email is stored as pending until VerifyEmail confirms it. When
+	// ifVersion is not empty the update only happens if the current version
+	// is one of them, otherwise ErrVersionMismatch is returned.
+	UpdateUserByID(ctx context.Context, name string, email string, id string, ifVersion []int) error
+	// VerifyEmail marks email as verified when it is the user's email, or
+	// makes it the user's email when it is pending. Other addresses give
+	// ErrNotFound, and an address taken in the meantime ErrConflict.
+	VerifyEmail(ctx context.Context, id string, email string) error
+	DeleteUserByID(ctx context.Context, id string) error
+	// GetTokenVersion returns the version access tokens of the user must
+	// carry. Deleted and disabled users are reported as ErrNotFound.
+	GetTokenVersion(ctx context.Context, id string) (int, error)
+	// UpdatePasswordByID stores a new password hash and bumps the token
+	// version, invalidating every access token issued before.
+	UpdatePasswordByID(ctx context.Context, id string, passwordHash string) error
+	// RehashPassword replaces oldHash by newHash of the same password without
+	// touching the token version. It returns ErrNotFound when the password
+	// changed in the meantime.
+	RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error
+	// SetUserDisabled disables or re-enables an account. Either way the
+	// token version is bumped.
+	SetUserDisabled(ctx context.Context, id string, disabled bool) error
+	// SetMagicLinkEnabled opts the user in to or out of magic link logins.
+	SetMagicLinkEnabled(ctx context.Context, id string, enabled bool) error
+	// SetUserRole changes the role and bumps the token version, so tokens
+	// carrying the old role stop working.
+	SetUserRole(ctx context.Context, id string, role string) error
+	SetUserRoleByEmail(ctx context.Context, email string, role string) error
+}
+
+// PostStore abstracts persistence of posts.
+type PostStore interface {
+	GetPostAll(ctx context.Context, page PageRequest) (*Page[models.Post], error)
+	GetPostByID(ctx context.Context, id string) (*models.Post, error)
+	GetPostOwnerID(ctx context.Context, id string) (string, error)
+	CreatePostInDB(ctx context.Context, title string, content string, userID string) error
+	// UpdatePostByID changes the post and records the result as a new
+	// revision attributed to editorID. A non-empty ifVersion lists the
+	// revisions the caller accepts, see UserStore.UpdateUserByID.
+	UpdatePostByID(ctx context.Context, title string, content string, id string, editorID string, ifVersion []int) error
+	DeletePostByID(ctx context.Context, id string) error
+}
+
+// RevisionStore exposes the history of post changes. Revisions of posts in
+// the trash are hidden like the posts themselves.
+type RevisionStore interface {
+	GetPostRevisions(ctx context.Context, postID string) (*[]models.PostRevision, error)
+	GetPostRevision(ctx context.Context, postID string, revision int) (*models.PostRevision, error)
+	// RollbackPost restores the title and content of an earlier revision as a
+	// new revision attributed to editorID.
+	RollbackPost(ctx context.Context, postID string, revision int, editorID string) error
+}
+
+// CommentStore abstracts persistence of comments.
+type CommentStore interface {
+	GetCommentByPostID(ctx context.Context, postID string, page PageRequest) (*Page[models.Comment], error)
+	// GetCommentOwnerIDs returns the authors of a comment and of its post.
+	GetCommentOwnerIDs(ctx context.Context, id string) (commentOwnerID string, postOwnerID string, err error)
+	CreateCommentInDB(ctx context.Context, comment string, userID string, postID string) error
+	DeleteCommentByID(ctx context.Context, id string) error
+}
+
+// SearchStore provides full-text search over posts and comments, ordered by
+// relevance and paginated like every other listing.
+type SearchStore interface {
+	Search(ctx context.Context, query string, page PageRequest) (*Page[models.SearchResult], error)
+}
+
+// TrashStore manages soft-deleted posts and comments. Restores are scoped to
+// the owner, so other users get ErrNotFound.
+type TrashStore interface {
+	GetTrash(ctx context.Context, userID string) (*[]models.TrashItem, error)
+	RestorePost(ctx context.Context, id string, userID string) error
+	// RestoreComment returns ErrConflict while the post of the comment is
+	// in the trash itself.
+	RestoreComment(ctx context.Context, id string, userID string) error
+	// PurgeDeleted permanently removes items deleted before the given time
+	// and returns how many rows were removed.
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RefreshTokenStore keeps hashed refresh tokens. Expired and revoked tokens
+// are reported as ErrNotFound. A token family is the session it was issued
+// for, see SessionStore.CreateSession.
+type RefreshTokenStore interface {
+	// RotateRefreshToken marks tokenHash as used and stores newHash in the
+	// same family. Presenting a used token again revokes the session and
+	// returns ErrTokenReused.
+	RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (*models.RefreshToken, error)
+	// RevokeRefreshFamily revokes the session tokenHash belongs to.
+	RevokeRefreshFamily(ctx context.Context, tokenHash string) error
+}
+
+// SessionStore tracks logins. Revoking a session also revokes its refresh
+// tokens, and revoked sessions are reported as ErrNotFound.
+type SessionStore interface {
+	// CreateSession starts a session together with its first refresh token.
+	CreateSession(ctx context.Context, userID string, userAgent string, ip string, tokenHash string, expiresAt time.Time) (*models.Session, error)
+	GetSessions(ctx context.Context, userID string) (*[]models.Session, error)
+	// TouchSession checks that the session of userID is still live and
+	// records that it has been seen.
+	TouchSession(ctx context.Context, id string, userID string) error
+	RevokeSession(ctx context.Context, id string, userID string) error
+	// RevokeAllSessions logs userID out everywhere and returns how many
+	// sessions were revoked.
+	RevokeAllSessions(ctx context.Context, userID string) (int64, error)
+}
+
+// PasswordResetStore keeps hashed password reset tokens. Used and expired
+// tokens are reported as ErrNotFound.
+type PasswordResetStore interface {
+	// CreatePasswordReset stores a new token for userID, replacing the
+	// unused ones issued before.
+	CreatePasswordReset(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error
+	// CheckPasswordReset returns the id of the user tokenHash was issued
+	// to without consuming it.
+	CheckPasswordReset(ctx context.Context, tokenHash string) (string, error)
+	// ResetPassword consumes tokenHash, sets passwordHash on its user and
+	// bumps the token version. It returns the id of the user.
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string) (string, error)
+}
+
+// MagicLinkStore keeps the single-use magic links mailed to users. Used and
+// expired links are reported as ErrNotFound.
+type MagicLinkStore interface {
+	// CreateMagicLink stores a new link for userID, replacing the ones
+	// issued before, and returns its id.
+	CreateMagicLink(ctx context.Context, userID string, expiresAt time.Time) (string, error)
+	// UseMagicLink consumes the link id issued to userID.
+	UseMagicLink(ctx context.Context, id string, userID string) error
+}
+
+// TwoFactorStore keeps TOTP authenticators and recovery codes. Users without
+// an authenticator are reported as ErrNotFound.
+type TwoFactorStore interface {
+	GetTOTP(ctx context.Context, userID string) (*models.TOTP, error)
+	// SetTOTPSecret starts a new enrollment, replacing an unconfirmed one.
+	// It returns ErrConflict when two-factor authentication is enabled.
+	SetTOTPSecret(ctx context.Context, userID string, secret string) error
+	// ConfirmTOTP enables the pending authenticator after a code of time
+	// step counter was accepted and replaces the recovery codes.
+	ConfirmTOTP(ctx context.Context, userID string, counter int64, recoveryHashes []string) error
+	// UseTOTPCounter records that the code of time step counter was used.
+	// Steps at or before the last used one give ErrTokenReused.
+	UseTOTPCounter(ctx context.Context, userID string, counter int64) error
+	// UseRecoveryCode consumes an unused recovery code of userID.
+	UseRecoveryCode(ctx context.Context, userID string, codeHash string) error
+	// DeleteTOTP disables two-factor authentication and drops the recovery
+	// codes.
+	DeleteTOTP(ctx context.Context, userID string) error
+}
+
+// AttemptStore tracks failed logins for brute-force protection. Keys are
+// opaque, e.g. "account:<email>" or "ip:<address>".
+type AttemptStore interface {
+	// GetLoginAttempt returns the state of key. Unknown keys give a zero
+	// LoginAttempt, not an error.
+	GetLoginAttempt(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordLoginFailure counts a failure for key and returns the new state.
+	// The count restarts when the last failure is older than window.
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempt, error)
+	// LockLogin refuses attempts for key until the given time.
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ResetLoginAttempts forgets the failures of key.
+	ResetLoginAttempts(ctx context.Context, key string) error
+	// PurgeLoginAttempts removes keys whose last failure is before the given
+	// time and which are not locked anymore.
+	PurgeLoginAttempts(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PersonalTokenStore keeps hashed personal access tokens. Expired and revoked
+// tokens are reported as ErrNotFound.
+type PersonalTokenStore interface {
+	CreatePersonalToken(ctx context.Context, userID string, name string, tokenHash string, scopes []string, expiresAt time.Time) (*models.PersonalToken, error)
+	// GetPersonalTokens lists the live tokens of userID, newest first.
+	GetPersonalTokens(ctx context.Context, userID string) (*[]models.PersonalToken, error)
+	// AuthenticatePersonalToken returns the live token with tokenHash
+	// together with the role of its user, and records that it has been used.
+	AuthenticatePersonalToken(ctx context.Context, tokenHash string) (*models.PersonalToken, error)
+	RevokePersonalToken(ctx context.Context, id string, userID string) error
+	// RevokeAllPersonalTokens revokes the live tokens of userID and returns
+	// how many were revoked.
+	RevokeAllPersonalTokens(ctx context.Context, userID string) (int64, error)
+}
+
+// IdentityStore links accounts at external OpenID Connect providers to
+// users.
+type IdentityStore interface {
+	// GetIdentityUserID returns the user subject at provider is linked to.
+	GetIdentityUserID(ctx context.Context, provider string, subject string) (string, error)
+	// LinkIdentity links subject at provider to userID. A subject linked
+	// already gives ErrConflict.
+	LinkIdentity(ctx context.Context, userID string, provider string, subject string, email string) error
+}
+
+// Store groups every storage interface. Both PostgresStore and MemoryStore
+// satisfy it.
+type Store interface {
+	UserStore
+	PostStore
+	RevisionStore
+	CommentStore
+	SearchStore
+	TrashStore
+	RefreshTokenStore
+	SessionStore
+	PasswordResetStore
+	MagicLinkStore
+	TwoFactorStore
+	AttemptStore
+	PersonalTokenStore
+	IdentityStore
+}
+
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
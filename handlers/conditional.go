@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag is the strong entity tag of a row at the given version.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// publicVersionETag tags the reduced representation of a row that callers
+// without access to its private fields get.
+func publicVersionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `-public"`
+}
+
+// ifMatchVersions reads the If-Match header of a write request and returns
+// the versions the client is willing to overwrite. nil means the write is
+// unconditional, either because the header is absent or because it is "*".
+// When the request cannot proceed it writes 428 or 412 and returns false.
+func (s *Server) ifMatchVersions(w http.ResponseWriter, r *http.Request) ([]int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if s.RequireIfMatch {
+			utils.JSONError(w, "If-Match header is required", http.StatusPreconditionRequired)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	var versions []int
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		// If-Match uses the strong comparison, weak tags never match
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue
+		}
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		if !ok {
+			continue
+		}
+		if version, err := strconv.Atoi(unquoted); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		slog.WarnContext(r.Context(), "If-Match cannot match any version", "if_match", header)
+		utils.JSONError(w, "Precondition failed", http.StatusPreconditionFailed)
+		return nil, false
+	}
+
+	return versions, true
+}
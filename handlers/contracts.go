@@ -1,9 +1,140 @@
 package handlers
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"gopher-post/db"
+	"gopher-post/jwtauth"
+	"gopher-post/lockout"
+	"gopher-post/mailer"
+	"gopher-post/middleware"
+	"gopher-post/oidc"
+	"gopher-post/workpool"
+	"runtime"
+	"time"
+)
 
 type Server struct {
-	DB *pgxpool.Pool
+	Users     db.UserStore
+	Posts     db.PostStore
+	Revisions db.RevisionStore
+	Comments  db.CommentStore
+	Search    db.SearchStore
+	Trash     db.TrashStore
+	Tokens    db.RefreshTokenStore
+	Sessions  db.SessionStore
+
+	PasswordResets db.PasswordResetStore
+	MagicLinks     db.MagicLinkStore
+	TwoFactor      db.TwoFactorStore
+	PersonalTokens db.PersonalTokenStore
+	Identities     db.IdentityStore
+
+	// OIDCProviders are the identity providers users can log in with, by
+	// name.
+	OIDCProviders map[string]*oidc.Provider
+
+	// Keys signs access tokens, the auth middleware verifies them with the
+	// same set.
+	Keys *jwtauth.KeySet
+	// TokenVersions is shared with the auth middleware, handlers that bump
+	// a token version invalidate it.
+	TokenVersions *middleware.TokenVersionCache
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// MFATokenTTL is how long a login may take to submit its second factor.
+	MFATokenTTL time.Duration
+	// TOTPIssuer names the account in authenticator apps.
+	TOTPIssuer string
+	// PersonalTokenMaxTTL caps the lifetime users can pick for personal
+	// access tokens.
+	PersonalTokenMaxTTL time.Duration
+
+	// Mailer delivers password reset, verification and login links.
+	// PublicURL is the address of the frontend the links point to.
+	Mailer               mailer.Mailer
+	PublicURL            string
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	MagicLinkTTL         time.Duration
+
+	// RequireVerifiedEmail keeps users from posting and commenting until
+	// they confirmed their email.
+	RequireVerifiedEmail bool
+
+	// PasswordPool bounds concurrent password hashing, so a burst of logins
+	// cannot starve the rest of the API.
+	PasswordPool *workpool.Pool
+
+	// AccountLockout and IPLockout slow down and block password and code
+	// guessing per account and per client IP.
+	AccountLockout *lockout.Limiter
+	IPLockout      *lockout.Limiter
+
+	// RequireIfMatch makes updates without an If-Match header fail with
+	// 428 Precondition Required instead of overwriting blindly.
+	RequireIfMatch bool
+}
+
+// DefaultAccountLockout allows a few typos, then backs off and locks the
+// account after 10 failures in a row.
+var DefaultAccountLockout = lockout.Policy{
+	FreeFailures: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	MaxFailures:  10,
+	Lockout:      15 * time.Minute,
+	Window:       15 * time.Minute,
+}
+
+// DefaultIPLockout is looser, many users may share an address.
+var DefaultIPLockout = lockout.Policy{
+	FreeFailures: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	MaxFailures:  100,
+	Lockout:      15 * time.Minute,
+	Window:       15 * time.Minute,
+}
+
+// NewServer builds a Server whose stores are all backed by store and whose
+// tokens are signed with keys.
+func NewServer(store db.Store, keys *jwtauth.KeySet) *Server {
+	return &Server{
+		Users:     store,
+		Posts:     store,
+		Revisions: store,
+		Comments:  store,
+		Search:    store,
+		Trash:     store,
+		Tokens:    store,
+		Sessions:  store,
+
+		PasswordResets: store,
+		MagicLinks:     store,
+		TwoFactor:      store,
+		PersonalTokens: store,
+		Identities:     store,
+
+		Keys:          keys,
+		TokenVersions: middleware.NewTokenVersionCache(store, 5*time.Second),
+
+		PasswordPool:   workpool.New(runtime.NumCPU(), 4*runtime.NumCPU()),
+		AccountLockout: lockout.New(store, DefaultAccountLockout),
+		IPLockout:      lockout.New(store, DefaultIPLockout),
+
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		MFATokenTTL:     5 * time.Minute,
+		TOTPIssuer:      "GopherPost",
+
+		PersonalTokenMaxTTL: 365 * 24 * time.Hour,
+
+		Mailer:               &mailer.LogMailer{},
+		PublicURL:            "http://localhost:8080",
+		PasswordResetTTL:     time.Hour,
+		EmailVerificationTTL: 24 * time.Hour,
+		MagicLinkTTL:         15 * time.Minute,
+	}
 }
 
 // -- AUTH --
@@ -12,6 +143,62 @@ type LoginInput struct {
 	Password string `json:"password"`
 }
 
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+// TwoFactorLoginInput completes a login with either a code from the
+// authenticator or a recovery code.
+type TwoFactorLoginInput struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type TwoFactorCodeInput struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MagicLinkInput struct {
+	Email string `json:"email"`
+}
+
+// MagicLinkLoginInput carries the token of a mailed login link.
+type MagicLinkLoginInput struct {
+	Token string `json:"token"`
+}
+
+type SetMagicLinkInput struct {
+	Enabled bool `json:"enabled"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// CreatePersonalTokenInput names a new personal access token. ExpiresInDays
+// defaults to 30.
+type CreatePersonalTokenInput struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
 // -- USER --
 type RegisterInput struct {
 	Name     string `json:"name"`
@@ -19,6 +206,14 @@ type RegisterInput struct {
 	Password string `json:"password"`
 }
 
+type SetRoleInput struct {
+	Role string `json:"role"`
+}
+
+type SetDisabledInput struct {
+	Disabled bool `json:"disabled"`
+}
+
 type UpdateUserInput struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
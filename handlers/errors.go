@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"gopher-post/db"
+	"gopher-post/policy"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// storeErrorStatus maps storage errors to the HTTP status every endpoint
+// should answer with.
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrInvalidID):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, db.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeStoreError logs a failed storage call and writes the matching JSON
+// error. resource names the entity involved (e.g. "Post"); message is logged
+// and returned to the client for unexpected errors.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, resource string, message string, attrs ...any) {
+	if errors.Is(err, context.Canceled) {
+		// the client is gone, nobody will read the response
+		slog.InfoContext(r.Context(), "Request cancelled by client", append(attrs, "error", err)...)
+		return
+	}
+
+	status := storeErrorStatus(err)
+	attrs = append(attrs, "error", err, "status", status)
+
+	var clientMessage string
+	switch status {
+	case http.StatusNotFound:
+		clientMessage = resource + " not found"
+	case http.StatusConflict:
+		clientMessage = resource + " already exists"
+	case http.StatusBadRequest:
+		clientMessage = "Invalid " + strings.ToLower(resource) + " id"
+	case http.StatusGatewayTimeout:
+		clientMessage = "Database timeout, please retry"
+	case http.StatusPreconditionFailed:
+		clientMessage = resource + " was modified, fetch it again and retry"
+	default:
+		clientMessage = message
+	}
+
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), message, attrs...)
+	} else {
+		slog.WarnContext(r.Context(), message, attrs...)
+	}
+
+	utils.JSONError(w, clientMessage, status)
+}
+
+// writePolicyError answers a request denied by policy.Authorize with 401 when
+// nobody is logged in and 403 otherwise. message is returned to the client on
+// 403.
+func writePolicyError(w http.ResponseWriter, r *http.Request, err error, action policy.Action, message string, attrs ...any) {
+	if errors.Is(err, policy.ErrUnauthenticated) {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID", "action", action)
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	subject, _ := policy.SubjectFrom(r.Context())
+	slog.WarnContext(r.Context(), "Forbidden access", append(attrs,
+		"action", action,
+		"attempt_by_user_id", subject.UserID,
+		"role", subject.Role,
+	)...)
+	utils.JSONError(w, message, http.StatusForbidden)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gopher-post/middleware"
+	"gopher-post/models"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SetUserRoleHandler godoc
+// @Summary      Change user role
+// @Description  Sets the role of a user to user, moderator or admin. Admin only. Moderators and admins must have two-factor authentication enabled. Existing tokens of the user stop working so the new role applies right away.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "User ID (UUID)"
+// @Param        request body handlers.SetRoleInput true "New role"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/users/{id}/role [put]
+func (s *Server) SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	currentUserID, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	var input SetRoleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || !models.ValidRole(input.Role) {
+		utils.JSONError(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requireTwoFactor(w, r, id, input.Role) {
+		return
+	}
+
+	err := s.Users.SetUserRole(r.Context(), id, input.Role)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed set user role", "user_id", id)
+		return
+	}
+	s.TokenVersions.Invalidate(id)
+
+	slog.InfoContext(r.Context(), "User role changed",
+		"user_id", id,
+		"role", input.Role,
+		"changed_by_user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "user role updated"}, http.StatusOK)
+}
+
+// SetUserDisabledHandler godoc
+// @Summary      Disable or enable user
+// @Description  Disables or re-enables an account. Admin only. Disabling logs the user out everywhere and rejects their tokens immediately.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "User ID (UUID)"
+// @Param        request body handlers.SetDisabledInput true "Disabled flag"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/users/{id}/disabled [put]
+func (s *Server) SetUserDisabledHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	currentUserID, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	var input SetDisabledInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.JSONError(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if input.Disabled && id == currentUserID {
+		utils.JSONError(w, "You cannot disable your own account", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Users.SetUserDisabled(r.Context(), id, input.Disabled)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed set user disabled", "user_id", id)
+		return
+	}
+	s.TokenVersions.Invalidate(id)
+
+	if input.Disabled {
+		if _, err := s.Sessions.RevokeAllSessions(r.Context(), id); err != nil {
+			writeStoreError(w, r, err, "User", "Failed revoke sessions", "user_id", id)
+			return
+		}
+	}
+
+	slog.InfoContext(r.Context(), "User disabled flag changed",
+		"user_id", id,
+		"disabled", input.Disabled,
+		"changed_by_user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "user updated"}, http.StatusOK)
+}
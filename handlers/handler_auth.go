@@ -2,23 +2,31 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"gopher-post/db"
+	"gopher-post/jwtauth"
+	"gopher-post/middleware"
+	"gopher-post/models"
 	"gopher-post/utils"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 // LoginHandler godoc
 // @Summary      Masuk ke aplikasi
-// @Description  Tukar email dan password dengan Token JWT
+// @Description  Tukar email dan password dengan access token JWT berumur pendek dan refresh token. Jika 2FA aktif, yang dikembalikan adalah mfa_token untuk POST /auth/2fa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body handlers.LoginInput true "Kredensial Login"
-// @Success      200  {object}  handlers.LoginResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      401  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.LoginResponse
+// @Success      202  {object}  utils.MFAChallengeResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      429  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Failure      503  {object}  utils.ErrorResponse
 // @Router       /login [post]
 func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var input LoginInput
@@ -29,27 +37,297 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := db.GetUserByEmail(s.DB, input.Email)
-	if err != nil {
+	keys := s.loginKeys(r, input.Email)
+	if !s.checkAttemptAllowed(w, r, keys) {
+		return
+	}
+
+	user, err := s.Users.GetUserByEmail(r.Context(), input.Email)
+	if errors.Is(err, db.ErrNotFound) {
+		// burn the same hashing time as a wrong password would
+		if _, ok := s.checkPassword(w, r, input.Password, dummyPasswordHash()); !ok {
+			return
+		}
 		slog.WarnContext(r.Context(), "Login failed: Invalid email or password", "error", err)
-		utils.JSONError(w, "Invalid email or password", http.StatusUnauthorized)
+		s.attemptFailed(w, r, keys, "Invalid email or password")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Login failed: Database error")
+		return
+	}
+
+	match, ok := s.checkPassword(w, r, input.Password, user.PasswordHash)
+	if !ok {
+		return
+	}
+	if !match {
+		slog.WarnContext(r.Context(), "Login failed: Invalid email or password", "user_id", user.ID)
+		s.attemptFailed(w, r, keys, "Invalid email or password")
+		return
+	}
+	s.attemptSucceeded(r, keys)
+	s.upgradePasswordHash(r, user.ID, input.Password, user.PasswordHash)
+
+	s.finishLogin(w, r, user)
+}
+
+// finishLogin logs in user, who proved their identity with a password or at
+// an identity provider. Users with two-factor authentication get a
+// challenge instead of tokens.
+func (s *Server) finishLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if user.DisabledAt != nil {
+		slog.WarnContext(r.Context(), "Login refused: account disabled", "user_id", user.ID)
+		utils.JSONError(w, "Account disabled", http.StatusForbidden)
+		return
+	}
+
+	totp, err := s.TwoFactor.GetTOTP(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Login failed: Database error", "user_id", user.ID)
+		return
+	}
+	if totp.Enabled() {
+		s.writeMFAChallenge(w, r, user.ID)
+		return
+	}
+
+	s.startSession(w, r, user.ID, "login successful")
+}
+
+// upgradePasswordHash rehashes a verified password whose hash uses an old
+// algorithm or cost. Failing to do so does not fail the login.
+func (s *Server) upgradePasswordHash(r *http.Request, userID string, password string, oldHash string) {
+	if !utils.PasswordNeedsRehash(oldHash) {
+		return
+	}
+
+	var newHash string
+	var hashErr error
+	err := s.PasswordPool.Do(r.Context(), func() { newHash, hashErr = utils.HashPassword(password) })
+	if err != nil {
+		// try again on a later login rather than slow this one down
+		slog.InfoContext(r.Context(), "Password rehash skipped", "error", err, "user_id", userID)
+		return
+	}
+	if hashErr != nil {
+		slog.ErrorContext(r.Context(), "Failed rehashing password", "error", hashErr, "user_id", userID)
+		return
+	}
+	if err := s.Users.RehashPassword(r.Context(), userID, oldHash, newHash); err != nil {
+		slog.WarnContext(r.Context(), "Failed store rehashed password", "error", err, "user_id", userID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Password rehashed", "user_id", userID)
+}
+
+// startSession creates a session for userID, who has been fully
+// authenticated, and writes its tokens.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID string, message string) {
+	refreshToken, refreshHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := s.Sessions.CreateSession(r.Context(), userID, r.UserAgent(), clientIP(r), refreshHash, time.Now().Add(s.RefreshTokenTTL))
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Error creating session", "user_id", userID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Login succesfull", "session_id", session.ID)
+	s.writeTokens(w, r, userID, session.ID, refreshToken, message)
+}
+
+// RefreshHandler godoc
+// @Summary      Perbarui access token
+// @Description  Tukar refresh token dengan access token dan refresh token baru. Refresh token lama tidak bisa dipakai lagi; jika dipakai ulang, semua token dari login yang sama dicabut.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.RefreshTokenInput true "Refresh token"
+// @Success      200  {object}  utils.LoginResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/refresh [post]
+func (s *Server) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var input RefreshTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.RefreshToken == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, refreshHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
 
-	match := utils.CheckPasswordHash(input.Password, user.PasswordHash)
+	token, err := s.Tokens.RotateRefreshToken(r.Context(), utils.HashToken(input.RefreshToken), refreshHash, time.Now().Add(s.RefreshTokenTTL))
+	if errors.Is(err, db.ErrTokenReused) {
+		slog.WarnContext(r.Context(), "Refresh token reused, token family revoked", "error", err)
+		utils.JSONError(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Refresh failed: unknown, expired or revoked token")
+		utils.JSONError(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "Token", "Error rotating refresh token")
+		return
+	}
+
+	s.writeTokens(w, r, token.UserID, token.FamilyID, refreshToken, "token refreshed")
+}
+
+// LogoutHandler godoc
+// @Summary      Keluar
+// @Description  Mengakhiri sesi pemilik refresh token. Refresh token dan access token dari sesi itu tidak bisa dipakai lagi.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.RefreshTokenInput true "Refresh token"
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/logout [post]
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var input RefreshTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.RefreshToken == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	// logging out twice or with an unknown token is not an error for the client
+	err := s.Tokens.RevokeRefreshFamily(r.Context(), utils.HashToken(input.RefreshToken))
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "Token", "Error revoking refresh token")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Logout successful")
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "logged out"}, http.StatusOK)
+}
+
+// ChangePasswordHandler godoc
+// @Summary      Ganti password
+// @Description  Mengganti password user yang sedang login. Semua token dan sesi yang ada, termasuk personal access token, langsung dicabut, jadi user harus login ulang.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.ChangePasswordInput true "Password lama dan baru"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Failure      503  {object}  utils.ErrorResponse
+// @Router       /api/me/password [put]
+func (s *Server) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input ChangePasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.NewPassword == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.Users.GetUserByID(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", currentUserID)
+		return
+	}
+	user, err := s.Users.GetUserByEmail(r.Context(), profile.Email)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", currentUserID)
+		return
+	}
+
+	match, ok := s.checkPassword(w, r, input.CurrentPassword, user.PasswordHash)
+	if !ok {
+		return
+	}
 	if !match {
-		slog.WarnContext(r.Context(), "Invalid check email or password", "error", err)
-		utils.JSONError(w, "Invalid check email or password", http.StatusUnauthorized)
+		slog.WarnContext(r.Context(), "Change password failed: wrong current password", "user_id", currentUserID)
+		utils.JSONError(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	passwordHash, ok := s.hashPassword(w, r, input.NewPassword)
+	if !ok {
+		return
+	}
+
+	err = s.Users.UpdatePasswordByID(r.Context(), currentUserID, passwordHash)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed update password", "user_id", currentUserID)
+		return
+	}
+	s.TokenVersions.Invalidate(currentUserID)
+
+	// refresh tokens would otherwise keep minting access tokens
+	if _, err := s.Sessions.RevokeAllSessions(r.Context(), currentUserID); err != nil {
+		writeStoreError(w, r, err, "User", "Failed revoke sessions", "user_id", currentUserID)
+		return
+	}
+
+	// personal tokens are credentials of the account as well
+	if _, err := s.PersonalTokens.RevokeAllPersonalTokens(r.Context(), currentUserID); err != nil {
+		writeStoreError(w, r, err, "User", "Failed revoke personal tokens", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Password changed successfully", "user_id", currentUserID)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "password changed, please log in again"}, http.StatusOK)
+}
+
+// writeTokens issues a new access token for userID in sessionID and writes
+// it together with the already stored refreshToken.
+func (s *Server) writeTokens(w http.ResponseWriter, r *http.Request, userID string, sessionID string, refreshToken string, message string) {
+	// role and token version are read fresh, a refresh picks up role changes
+	user, err := s.Users.GetUserByID(r.Context(), userID)
+	if err == nil && user.DisabledAt != nil {
+		err = db.ErrNotFound
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Token refused: user deleted or disabled", "user_id", userID)
+		utils.JSONError(w, "Account disabled", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Error reading user", "user_id", userID)
 		return
 	}
 
-	token, err := utils.CreateToken(user.ID)
+	token, err := s.Keys.CreateAccessToken(jwtauth.AccessClaims{
+		UserID:       userID,
+		SessionID:    sessionID,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+	}, s.AccessTokenTTL)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
 		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
 
-	slog.InfoContext(r.Context(), "Login succesfull")
-	utils.JSONSuccess(w, utils.LoginResponse{Message: "login successful", Token: token}, http.StatusOK)
+	utils.JSONSuccess(w, utils.LoginResponse{
+		Message:      message,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.AccessTokenTTL.Seconds()),
+	}, http.StatusOK)
 }
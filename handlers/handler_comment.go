@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
-	"gopher-post/db"
 	"gopher-post/middleware"
+	"gopher-post/policy"
 	"gopher-post/utils"
 	"log/slog"
 	"net/http"
@@ -13,24 +13,34 @@ import (
 
 // GetCommentHandler godoc
 // @Summary      Dapatkan komentar
-// @Description  Mengambil semua komentar berdasarkan ID Postingan
+// @Description  Mengambil komentar berdasarkan ID Postingan dengan cursor pagination
 // @Tags         comments
 // @Produce      json
 // @Param        id path   string  true  "ID Postingan (UUID)"
-// @Success      200    {array}  models.Comment
-// @Failure	     400	{object} handlers.ErrorResponse
+// @Param        cursor        query    string  false  "Cursor dari next_cursor / prev_cursor"
+// @Param        limit         query    int     false  "Jumlah Data per Halaman (maks 100)"
+// @Param        include_total query    bool    false  "Sertakan jumlah total data"
+// @Success      200    {object} utils.PageResponse[models.Comment]
+// @Failure	     400	{object} utils.ErrorResponse
+// @Failure	     500	{object} utils.ErrorResponse
 // @Router       /posts/{id}/comments [get]
 func (s *Server) GetCommentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["id"]
 
-	result, err := db.GetCommentByPostID(s.DB, postID)
+	page, err := parsePageRequest(r)
 	if err != nil {
-		utils.JSONError(w, "Failed get comment", http.StatusBadRequest)
+		utils.JSONError(w, "Invalid cursor", http.StatusBadRequest)
 		return
 	}
 
-	utils.JSONSuccess(w, &result, http.StatusOK)
+	result, err := s.Comments.GetCommentByPostID(r.Context(), postID, page)
+	if err != nil {
+		writeStoreError(w, r, err, "Post", "Failed get comment", "post_id", postID)
+		return
+	}
+
+	writePage(w, r, result)
 }
 
 // CreateCommentHandler godoc
@@ -41,9 +51,10 @@ func (s *Server) GetCommentHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        id path   string  true  "ID Postingan (UUID)"
 // @Param        request body   handlers.CreateCommentInput true "Isi Komentar"
-// @Success      201  {object}  handlers.SuccessResponse
-// @Failure	     400  {object}  handlers.ErrorResponse
-// @Failure	     500  {object}  handlers.ErrorResponse
+// @Success      201  {object}  utils.SuccessResponse
+// @Failure	     400  {object}  utils.ErrorResponse
+// @Failure	     404  {object}  utils.ErrorResponse
+// @Failure	     500  {object}  utils.ErrorResponse
 // @Security     BearerAuth
 // @Router       /api/posts/{id}/comments [post]
 func (s *Server) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -57,16 +68,23 @@ func (s *Server) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	postID := vars["id"]
-	userID := r.Context().Value(middleware.UserIDKey).(string)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.requireVerifiedEmail(w, r, userID) {
+		return
+	}
 
-	err = db.CreateCommentInDB(s.DB, input.Content, userID, postID)
+	err = s.Comments.CreateCommentInDB(r.Context(), input.Content, userID, postID)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed create comment",
+		writeStoreError(w, r, err, "Post", "Failed create comment",
 			"post_id", postID,
 			"user_id", userID,
-			"error", err,
 		)
-		utils.JSONError(w, "Failed create comment", http.StatusInternalServerError)
 		return
 	}
 
@@ -79,54 +97,48 @@ func (s *Server) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 
 // DeleteCommentHandler godoc
 // @Summary      Hapus komentar
-// @Description  Menghapus comment permanen berdasarkan ID
+// @Description  Memindahkan comment ke trash berdasarkan ID. Comment bisa dipulihkan sampai masa retensi habis. Penulis post boleh menghapus comment di post-nya, moderator dan admin boleh menghapus comment siapa pun.
 // @Tags         comments
 // @Produce      json
 // @Param        id   path      string  true  "ID Komentar (UUID)"
 // @Security     BearerAuth
-// @Success      200  {object}  handlers.SuccessResponse
-// @Failure	     404  {object}  handlers.ErrorResponse
-// @Failure	     403  {object}  handlers.ErrorResponse
-// @Failure	     500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure	     400  {object}  utils.ErrorResponse
+// @Failure	     404  {object}  utils.ErrorResponse
+// @Failure	     403  {object}  utils.ErrorResponse
+// @Failure	     500  {object}  utils.ErrorResponse
 // @Router       /api/comments/{id} [delete]
 func (s *Server) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	commentID := vars["id"]
 
-	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
-	if !ok || currentUserID == "" {
-		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
-		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
-	}
-
-	ownerID, err := db.GetCommentOwnerID(s.DB, commentID)
+	ownerID, postOwnerID, err := s.Comments.GetCommentOwnerIDs(r.Context(), commentID)
 	if err != nil {
-		slog.WarnContext(r.Context(), "Delete failed: Comment not found",
-			"error", err,
-			"comment_id", commentID,
-		)
-		utils.JSONError(w, "Comment not found", http.StatusNotFound)
+		writeStoreError(w, r, err, "Comment", "Delete failed: Comment lookup", "comment_id", commentID)
 		return
 	}
 
-	if currentUserID != ownerID {
-		slog.WarnContext(r.Context(), "Delete failed: Forbidden access",
+	err = policy.Authorize(r.Context(), policy.CommentDelete, policy.Resource{OwnerID: ownerID, PostOwnerID: postOwnerID})
+	if err != nil {
+		writePolicyError(w, r, err, policy.CommentDelete, "You are not allowed to delete this comment",
 			"comment_id", commentID,
-			"attempt_by_user_id", currentUserID,
 			"target_owner_id", ownerID,
 		)
-		utils.JSONError(w, "You are not allowed to delete this comment", http.StatusForbidden)
+		return
+	}
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	err = db.DeleteCommentByID(s.DB, commentID)
+	err = s.Comments.DeleteCommentByID(r.Context(), commentID)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed delete comment",
-			"error", err,
+		writeStoreError(w, r, err, "Comment", "Failed delete comment",
 			"comment_id", commentID,
 			"user_id", currentUserID,
 		)
-		utils.JSONError(w, "Failed delete comment", http.StatusInternalServerError)
 		return
 	}
 
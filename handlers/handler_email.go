@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/mailer"
+	"gopher-post/middleware"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// VerifyEmailHandler godoc
+// @Summary      Verifikasi email
+// @Description  Mengonfirmasi email dengan token dari link verifikasi. Untuk perubahan email, email baru baru berlaku setelah langkah ini.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.VerifyEmailInput true "Token verifikasi"
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/verify-email [post]
+func (s *Server) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var input VerifyEmailInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := s.Keys.ParseEmailToken(input.Token)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Verify email failed: invalid token", "error", err)
+		utils.JSONError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+
+	err = s.Users.VerifyEmail(r.Context(), claims.UserID, claims.Email)
+	if errors.Is(err, db.ErrNotFound) {
+		// the address was replaced by a newer pending one, or the user is gone
+		slog.WarnContext(r.Context(), "Verify email failed: stale token", "user_id", claims.UserID)
+		utils.JSONError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, db.ErrConflict) {
+		utils.JSONError(w, "Email already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed verify email", "user_id", claims.UserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Email verified", "user_id", claims.UserID)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "email verified"}, http.StatusOK)
+}
+
+// ResendVerificationHandler godoc
+// @Summary      Kirim ulang verifikasi email
+// @Description  Mengirim ulang link verifikasi ke email yang belum dikonfirmasi, atau ke email baru yang masih menunggu konfirmasi.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  utils.SuccessResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/email/verification [post]
+func (s *Server) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", currentUserID)
+		return
+	}
+
+	email := user.PendingEmail
+	if email == "" {
+		if user.EmailVerifiedAt != nil {
+			utils.JSONError(w, "Email already verified", http.StatusConflict)
+			return
+		}
+		email = user.Email
+	}
+
+	go s.sendEmailVerification(context.WithoutCancel(r.Context()), user.ID, user.Name, email)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "verification email sent"}, http.StatusAccepted)
+}
+
+// sendEmailVerification mails a signed link confirming that userID owns
+// email. It is meant to run in the background.
+func (s *Server) sendEmailVerification(ctx context.Context, userID string, name string, email string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	token, err := s.Keys.CreateEmailToken(userID, email, s.EmailVerificationTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error generating verification token", "user_id", userID, "error", err)
+		return
+	}
+
+	link := s.PublicURL + "/verify-email?token=" + url.QueryEscape(token)
+	err = s.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your GopherPost email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to confirm this email address. It expires in %s.\n\n%s\n\nIf you did not sign up or change your email, ignore this email.\n",
+			name, s.EmailVerificationTTL, link),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed send verification mail", "user_id", userID, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Verification mail sent", "user_id", userID)
+}
+
+// requireVerifiedEmail answers 403 and returns false when verified emails are
+// required and userID has not confirmed theirs.
+func (s *Server) requireVerifiedEmail(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if !s.RequireVerifiedEmail {
+		return true
+	}
+
+	user, err := s.Users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", userID)
+		return false
+	}
+	if user.EmailVerifiedAt == nil {
+		slog.WarnContext(r.Context(), "Posting refused: email not verified", "user_id", userID)
+		utils.JSONError(w, "Verify your email before posting", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
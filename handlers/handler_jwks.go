@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"gopher-post/utils"
+	"net/http"
+)
+
+// JWKSHandler godoc
+// @Summary      Kunci publik JWT
+// @Description  JSON Web Key Set berisi kunci publik untuk memverifikasi access token GopherPost. Kunci lama tetap tercantum selama masa rotasi.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  jwtauth.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (s *Server) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSONSuccess(w, s.Keys.JWKS(), http.StatusOK)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/mailer"
+	"gopher-post/middleware"
+	"gopher-post/models"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// magicLinkMessage is returned whether or not a link was sent.
+const magicLinkMessage = "if the email is registered and allows it, a login link has been sent"
+
+// MagicLinkHandler godoc
+// @Summary      Minta link login
+// @Description  Mengirim link login sekali pakai yang berumur pendek ke email user, tanpa password. Respons selalu sama, terdaftar atau tidak. Permintaan dibatasi per email dan per IP, terpisah dari penguncian login dengan password.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.MagicLinkInput true "Email akun"
+// @Success      202  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      429  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/magic-link [post]
+func (s *Server) MagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var input MagicLinkInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Email == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	// every link sent counts like a failed attempt until one is used, which
+	// keeps the endpoint from flooding an inbox
+	keys := s.magicLinkRequestKeys(r, input.Email)
+	if !s.checkAttemptAllowed(w, r, keys) {
+		return
+	}
+	if _, err := s.recordAttempt(r, keys); err != nil {
+		writeStoreError(w, r, err, "User", "Failed record login attempt")
+		return
+	}
+
+	user, err := s.Users.GetUserByEmail(r.Context(), input.Email)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Magic link failed: Database error")
+		return
+	}
+
+	if err == nil && user.DisabledAt == nil && user.MagicLinkEnabled {
+		go s.sendMagicLink(context.WithoutCancel(r.Context()), user)
+	} else {
+		slog.InfoContext(r.Context(), "Magic link requested for unknown, disabled or opted out account")
+	}
+
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: magicLinkMessage}, http.StatusAccepted)
+}
+
+// sendMagicLink stores a new login link for user and mails it. It is meant
+// to run in the background.
+func (s *Server) sendMagicLink(ctx context.Context, user *models.User) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	linkID, err := s.MagicLinks.CreateMagicLink(ctx, user.ID, time.Now().Add(s.MagicLinkTTL))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed store magic link", "user_id", user.ID, "error", err)
+		return
+	}
+	token, err := s.Keys.CreateMagicLinkToken(linkID, user.ID, user.Email, s.MagicLinkTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error generating magic link token", "user_id", user.ID, "error", err)
+		return
+	}
+
+	link := s.PublicURL + "/magic-link?token=" + url.QueryEscape(token)
+	err = s.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your GopherPost login link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to log in. It expires in %s and works once.\n\n%s\n\nIf you did not ask for this, ignore this email.\n",
+			user.Name, s.MagicLinkTTL, link),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed send magic link mail", "user_id", user.ID, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Magic link mail sent", "user_id", user.ID)
+}
+
+// MagicLinkLoginHandler godoc
+// @Summary      Login dengan link
+// @Description  Menukar token dari link login dengan access token dan refresh token, sama seperti /login. Link hanya bisa dipakai sekali. Jika 2FA aktif, yang dikembalikan adalah mfa_token untuk POST /auth/2fa.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.MagicLinkLoginInput true "Token dari link login"
+// @Success      200  {object}  utils.LoginResponse
+// @Success      202  {object}  utils.MFAChallengeResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      429  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/magic-link/login [post]
+func (s *Server) MagicLinkLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var input MagicLinkLoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	keys := s.magicLinkKeys(r)
+	if !s.checkAttemptAllowed(w, r, keys) {
+		return
+	}
+
+	claims, err := s.Keys.ParseMagicLinkToken(input.Token)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Magic link login failed: invalid token", "error", err)
+		s.attemptFailed(w, r, keys, "Invalid or expired login link")
+		return
+	}
+
+	err = s.MagicLinks.UseMagicLink(r.Context(), claims.ID, claims.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Magic link login failed: used, replaced or expired link", "user_id", claims.UserID)
+		s.attemptFailed(w, r, keys, "Invalid or expired login link")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed use magic link", "user_id", claims.UserID)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", claims.UserID)
+		return
+	}
+	// the link only stands for the address it was mailed to, and only while
+	// the user still wants such links
+	if user.Email != claims.Email || !user.MagicLinkEnabled {
+		slog.WarnContext(r.Context(), "Magic link login failed: email changed or opted out", "user_id", user.ID)
+		s.attemptFailed(w, r, keys, "Invalid or expired login link")
+		return
+	}
+
+	// opening the link proves control of the address
+	if user.EmailVerifiedAt == nil {
+		if err := s.Users.VerifyEmail(r.Context(), user.ID, user.Email); err != nil {
+			slog.WarnContext(r.Context(), "Failed verify email from magic link", "user_id", user.ID, "error", err)
+		}
+	}
+	s.attemptSucceeded(r, s.loginKeys(r, user.Email))
+	s.attemptSucceeded(r, s.magicLinkRequestKeys(r, user.Email))
+
+	s.finishLogin(w, r, user)
+}
+
+// SetMagicLinkHandler godoc
+// @Summary      Atur login dengan link
+// @Description  Mengaktifkan atau menonaktifkan login lewat link email untuk akun sendiri. Link yang sudah terkirim tidak berlaku lagi setelah dinonaktifkan.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.SetMagicLinkInput true "Aktif atau tidak"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/magic-link [put]
+func (s *Server) SetMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input SetMagicLinkInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Users.SetMagicLinkEnabled(r.Context(), currentUserID, input.Enabled); err != nil {
+		writeStoreError(w, r, err, "User", "Failed set magic link", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Magic link setting changed", "user_id", currentUserID, "enabled", input.Enabled)
+	message := "magic link login disabled"
+	if input.Enabled {
+		message = "magic link login enabled"
+	}
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: message}, http.StatusOK)
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"gopher-post/db"
+	"gopher-post/jwtauth"
+	"gopher-post/models"
+	"gopher-post/oidc"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	oidcStateCookie = "gopherpost_oidc"
+	oidcStateTTL    = 10 * time.Minute
+	// noPasswordHash is stored for users created through a provider. No
+	// password matches it until one is set with a password reset.
+	noPasswordHash = "!"
+)
+
+// OIDCLoginHandler godoc
+// @Summary      Login lewat provider OIDC
+// @Description  Mengarahkan browser ke halaman login provider (authorization code + PKCE). Provider kembali ke /auth/oidc/{provider}/callback.
+// @Tags         auth
+// @Param        provider  path  string  true  "Nama provider, misalnya google"
+// @Success      302
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      502  {object}  utils.ErrorResponse
+// @Router       /auth/oidc/{provider}/login [get]
+func (s *Server) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.OIDCProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		utils.JSONError(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	state, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	nonce, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed discover provider", "provider", provider.Name, "error", err)
+		utils.JSONError(w, "Provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	cookie, err := s.Keys.CreateOIDCStateToken(jwtauth.OIDCStateClaims{
+		Provider: provider.Name,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}, oidcStateTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    cookie,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secureCookies(r),
+		// Lax still sends it on the top-level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler godoc
+// @Summary      Callback login OIDC
+// @Description  Menukar code dari provider dengan token GopherPost. Akun provider ditautkan ke user dengan email terverifikasi yang sama, atau user baru dibuat. User ber-2FA mendapat mfa_token.
+// @Tags         auth
+// @Produce      json
+// @Param        provider  path   string  true  "Nama provider"
+// @Param        code      query  string  true  "Authorization code"
+// @Param        state     query  string  true  "State dari login"
+// @Success      200  {object}  utils.LoginResponse
+// @Success      202  {object}  utils.MFAChallengeResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/oidc/{provider}/callback [get]
+func (s *Server) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.OIDCProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		utils.JSONError(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	// the state is single use, whatever happens next
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true, Secure: s.secureCookies(r)})
+
+	query := r.URL.Query()
+	if errorCode := query.Get("error"); errorCode != "" {
+		slog.WarnContext(r.Context(), "Provider refused login", "provider", provider.Name, "error", errorCode)
+		utils.JSONError(w, "Login at provider failed: "+errorCode, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		utils.JSONError(w, "Login state missing, start again", http.StatusBadRequest)
+		return
+	}
+	state, err := s.Keys.ParseOIDCStateToken(cookie.Value)
+	if err != nil || state.Provider != provider.Name || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		slog.WarnContext(r.Context(), "OIDC callback with invalid state", "provider", provider.Name, "error", err)
+		utils.JSONError(w, "Invalid login state, start again", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code") == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		slog.WarnContext(r.Context(), "OIDC code exchange failed", "provider", provider.Name, "error", err)
+		utils.JSONError(w, "Login at provider failed", http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := s.userForIdentity(w, r, provider.Name, claims)
+	if !ok {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "OIDC login", "provider", provider.Name, "user_id", user.ID)
+	s.finishLogin(w, r, user)
+}
+
+// userForIdentity finds the user linked to the provider account, links an
+// existing user with the same verified email or creates a new one. It
+// writes the error response itself.
+func (s *Server) userForIdentity(w http.ResponseWriter, r *http.Request, provider string, claims *oidc.Claims) (*models.User, bool) {
+	userID, err := s.Identities.GetIdentityUserID(r.Context(), provider, claims.Subject)
+	if err == nil {
+		user, err := s.Users.GetUserByID(r.Context(), userID)
+		if err != nil {
+			writeStoreError(w, r, err, "User", "Failed get linked user", "user_id", userID)
+			return nil, false
+		}
+		return user, true
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Failed get identity", "provider", provider)
+		return nil, false
+	}
+
+	// only an email the provider vouches for may be linked or registered
+	email := strings.TrimSpace(claims.Email)
+	if !bool(claims.EmailVerified) || !utils.ValidEmail(email) {
+		slog.WarnContext(r.Context(), "OIDC login refused: email not verified by provider", "provider", provider)
+		utils.JSONError(w, "The provider did not confirm your email", http.StatusForbidden)
+		return nil, false
+	}
+
+	user, err := s.Users.GetUserByEmail(r.Context(), email)
+	if errors.Is(err, db.ErrNotFound) {
+		user, err = s.createIdentityUser(r.Context(), claims.Name, email)
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user for identity", "provider", provider)
+		return nil, false
+	}
+	// whoever registered the address without confirming it must not gain
+	// access to the provider account's logins
+	if user.EmailVerifiedAt == nil {
+		slog.WarnContext(r.Context(), "OIDC link refused: local email not verified", "provider", provider, "user_id", user.ID)
+		utils.JSONError(w, "An account with this email exists, log in with your password and verify the email first", http.StatusConflict)
+		return nil, false
+	}
+
+	err = s.Identities.LinkIdentity(r.Context(), user.ID, provider, claims.Subject, email)
+	if err != nil {
+		writeStoreError(w, r, err, "Identity", "Failed link identity", "provider", provider, "user_id", user.ID)
+		return nil, false
+	}
+
+	slog.InfoContext(r.Context(), "Identity linked", "provider", provider, "user_id", user.ID)
+	return user, true
+}
+
+// createIdentityUser registers a user whose email has been verified by a
+// provider. It has no password.
+func (s *Server) createIdentityUser(ctx context.Context, name string, email string) (*models.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	if err := s.Users.CreateUserInDB(ctx, name, email, noPasswordHash); err != nil {
+		return nil, err
+	}
+	user, err := s.Users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Users.VerifyEmail(ctx, user.ID, email); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "User created from identity", "user_id", user.ID)
+	return s.Users.GetUserByEmail(ctx, email)
+}
+
+// secureCookies reports whether cookies should only travel over HTTPS.
+func (s *Server) secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(s.PublicURL, "https://")
+}
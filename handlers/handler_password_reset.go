@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/mailer"
+	"gopher-post/models"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// forgotPasswordMessage is returned whether or not the email is registered.
+const forgotPasswordMessage = "if the email is registered, a reset link has been sent"
+
+// ForgotPasswordHandler godoc
+// @Summary      Lupa password
+// @Description  Mengirim link reset password sekali pakai ke email user. Respons selalu sama, terdaftar atau tidak, supaya email tidak bisa ditebak.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.ForgotPasswordInput true "Email akun"
+// @Success      202  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/forgot-password [post]
+func (s *Server) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input ForgotPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Email == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.Users.GetUserByEmail(r.Context(), input.Email)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Forgot password failed: Database error")
+		return
+	}
+
+	if err == nil && user.DisabledAt == nil {
+		// storing and mailing run in the background so the response time
+		// does not tell registered emails apart
+		ctx := context.WithoutCancel(r.Context())
+		go s.sendPasswordReset(ctx, user)
+	} else {
+		slog.InfoContext(r.Context(), "Password reset requested for unknown or disabled account")
+	}
+
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: forgotPasswordMessage}, http.StatusAccepted)
+}
+
+// sendPasswordReset stores a new reset token for user and mails the link.
+func (s *Server) sendPasswordReset(ctx context.Context, user *models.User) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error generating reset token", "error", err)
+		return
+	}
+
+	err = s.PasswordResets.CreatePasswordReset(ctx, user.ID, hash, time.Now().Add(s.PasswordResetTTL))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed store password reset", "user_id", user.ID, "error", err)
+		return
+	}
+
+	link := s.PublicURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = s.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your GopherPost password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password. It expires in %s and works once.\n\n%s\n\nIf you did not ask for this, ignore this email.\n",
+			user.Name, s.PasswordResetTTL, link),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed send password reset mail", "user_id", user.ID, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Password reset mail sent", "user_id", user.ID)
+}
+
+// ResetPasswordHandler godoc
+// @Summary      Reset password
+// @Description  Mengganti password dengan token dari email lupa password. Token hanya bisa dipakai sekali, dan semua sesi serta personal access token user dicabut.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.ResetPasswordInput true "Token reset dan password baru"
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Failure      503  {object}  utils.ErrorResponse
+// @Router       /auth/reset-password [post]
+func (s *Server) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input ResetPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" || input.NewPassword == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	// bogus tokens must not cost a slot on the password pool
+	tokenHash := utils.HashToken(input.Token)
+	_, err := s.PasswordResets.CheckPasswordReset(r.Context(), tokenHash)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Reset password failed: invalid or expired token")
+		utils.JSONError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed check reset token")
+		return
+	}
+
+	passwordHash, ok := s.hashPassword(w, r, input.NewPassword)
+	if !ok {
+		return
+	}
+
+	// the token is only consumed here, it may have been used in the meantime
+	userID, err := s.PasswordResets.ResetPassword(r.Context(), tokenHash, passwordHash)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Reset password failed: invalid or expired token")
+		utils.JSONError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed reset password")
+		return
+	}
+	s.TokenVersions.Invalidate(userID)
+
+	if _, err := s.Sessions.RevokeAllSessions(r.Context(), userID); err != nil {
+		writeStoreError(w, r, err, "User", "Failed revoke sessions", "user_id", userID)
+		return
+	}
+
+	// personal tokens are credentials of the account as well
+	if _, err := s.PersonalTokens.RevokeAllPersonalTokens(r.Context(), userID); err != nil {
+		writeStoreError(w, r, err, "User", "Failed revoke personal tokens", "user_id", userID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Password reset successfully", "user_id", userID)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "password reset, please log in"}, http.StatusOK)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gopher-post/middleware"
+	"gopher-post/models"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultPersonalTokenTTL = 30 * 24 * time.Hour
+
+// GetPersonalTokensHandler godoc
+// @Summary      Lihat personal access token
+// @Description  Mengambil personal access token user yang masih aktif, yang terbaru lebih dulu. Nilai token tidak pernah ditampilkan lagi.
+// @Tags         tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.PersonalToken
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/tokens [get]
+func (s *Server) GetPersonalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := s.PersonalTokens.GetPersonalTokens(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get personal tokens", "user_id", currentUserID)
+		return
+	}
+
+	utils.JSONSuccess(w, tokens, http.StatusOK)
+}
+
+// CreatePersonalTokenHandler godoc
+// @Summary      Buat personal access token
+// @Description  Membuat token bernama untuk bot atau skrip, dengan scope (posts:write, comments:write) dan masa berlaku (default 30 hari). Token hanya ditampilkan sekali dan dipakai sebagai Bearer token.
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.CreatePersonalTokenInput true "Nama, scope, dan masa berlaku"
+// @Security     BearerAuth
+// @Success      201  {object}  utils.PersonalTokenResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/tokens [post]
+func (s *Server) CreatePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input CreatePersonalTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		utils.JSONError(w, "Name is required and at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(input.Scopes) == 0 {
+		utils.JSONError(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			utils.JSONError(w, "Unknown scope "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+	slices.Sort(input.Scopes)
+	input.Scopes = slices.Compact(input.Scopes)
+
+	ttl := defaultPersonalTokenTTL
+	if input.ExpiresInDays != 0 {
+		ttl = time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	}
+	if ttl <= 0 || ttl > s.PersonalTokenMaxTTL {
+		utils.JSONError(w, "Invalid expires_in_days", http.StatusBadRequest)
+		return
+	}
+
+	token, hash, err := utils.NewPersonalToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	created, err := s.PersonalTokens.CreatePersonalToken(r.Context(), currentUserID, input.Name, hash, input.Scopes, time.Now().Add(ttl))
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed create personal token", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Personal token created",
+		"user_id", currentUserID,
+		"token_id", created.ID,
+		"scopes", created.Scopes,
+	)
+	utils.JSONSuccess(w, utils.PersonalTokenResponse{
+		Message:       "token created, copy it now, it will not be shown again",
+		Token:         token,
+		PersonalToken: *created,
+	}, http.StatusCreated)
+}
+
+// RevokePersonalTokenHandler godoc
+// @Summary      Cabut personal access token
+// @Description  Mencabut satu personal access token. Request dengan token itu langsung ditolak.
+// @Tags         tokens
+// @Produce      json
+// @Param        id   path      string  true  "ID Token (UUID)"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/tokens/{id} [delete]
+func (s *Server) RevokePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID := mux.Vars(r)["id"]
+
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := s.PersonalTokens.RevokePersonalToken(r.Context(), tokenID, currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "Token", "Failed revoke personal token",
+			"token_id", tokenID,
+			"user_id", currentUserID,
+		)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Personal token revoked",
+		"token_id", tokenID,
+		"user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "token revoked"}, http.StatusOK)
+}
@@ -2,47 +2,41 @@ package handlers
 
 import (
 	"encoding/json"
-	"gopher-post/db"
 	"gopher-post/middleware"
+	"gopher-post/policy"
 	"gopher-post/utils"
 	"log/slog"
 	"net/http"
-	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
 // GetAllPostsHandler godoc
 // @Summary      Melihat semua postingan
-// @Description  Mengambil daftar postingan dengan pagination
+// @Description  Mengambil daftar postingan dengan cursor pagination, urut berdasarkan (created_at, id)
 // @Tags         posts
 // @Produce      json
-// @Param        page  query    int     false  "Nomer Halaman"
-// @Param        limit query    int     false  "Jumlah Data per Halaman"
-// @Success      200   {array}  models.Post
-// @Failure      500   {object} handlers.ErrorResponse
+// @Param        cursor        query    string  false  "Cursor dari next_cursor / prev_cursor"
+// @Param        limit         query    int     false  "Jumlah Data per Halaman (maks 100)"
+// @Param        include_total query    bool    false  "Sertakan jumlah total data"
+// @Success      200   {object}  utils.PageResponse[models.Post]
+// @Failure      400   {object}  utils.ErrorResponse
+// @Failure      500   {object}  utils.ErrorResponse
 // @Router       /posts [get]
 func (s *Server) GetPostAllHandler(w http.ResponseWriter, r *http.Request) {
-	queryParams := r.URL.Query()
-	page, _ := strconv.Atoi(queryParams.Get("page"))
-	if page < 1 {
-		page = 1
-	}
-
-	limit, _ := strconv.Atoi(queryParams.Get("limit"))
-	if limit < 1 {
-		limit = 10
+	page, err := parsePageRequest(r)
+	if err != nil {
+		utils.JSONError(w, "Invalid cursor", http.StatusBadRequest)
+		return
 	}
 
-	offSet := (limit * page) - limit
-
-	posts, err := db.GetPostAll(s.DB, limit, offSet)
+	posts, err := s.Posts.GetPostAll(r.Context(), page)
 	if err != nil {
-		utils.JSONError(w, "Database error", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Post", "Database error")
 		return
 	}
 
-	utils.JSONSuccess(w, &posts, http.StatusOK)
+	writePage(w, r, posts)
 }
 
 // GetPostByIDHandler godoc
@@ -52,20 +46,22 @@ func (s *Server) GetPostAllHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        id   path      string  true  "ID Postingan (UUID)"
 // @Success      200   {object}  models.Post
-// @Failure      400   {object}  handlers.ErrorResponse
-// @Failure      404   {object}  handlers.ErrorResponse
-// @Failure      500   {object}  handlers.ErrorResponse
+// @Failure      400   {object}  utils.ErrorResponse
+// @Failure      404   {object}  utils.ErrorResponse
+// @Failure      500   {object}  utils.ErrorResponse
 // @Router       /posts/{id} [get]
 func (s *Server) GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	post, err := db.GetPostByID(s.DB, id)
+	post, err := s.Posts.GetPostByID(r.Context(), id)
 	if err != nil {
-		utils.JSONError(w, "Post not found or database error", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Post", "Failed get post", "post_id", id)
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(post.Revision))
+	w.Header().Set("Last-Modified", post.UpdatedAt.UTC().Format(http.TimeFormat))
 	utils.JSONSuccess(w, &post, http.StatusOK)
 }
 
@@ -76,10 +72,10 @@ func (s *Server) GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        request body handlers.CreatePostInput true "Data Post"
-// @Success      201  {object}  handlers.SuccessResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      401  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      201  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Security     BearerAuth
 // @Router       /api/posts [post]
 func (s *Server) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -97,13 +93,13 @@ func (s *Server) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.CreatePostInDB(s.DB, newPost.Title, newPost.Content, userID)
+	if !s.requireVerifiedEmail(w, r, userID) {
+		return
+	}
+
+	err = s.Posts.CreatePostInDB(r.Context(), newPost.Title, newPost.Content, userID)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed create post in DB",
-			"error", err,
-			"user_id", userID,
-		)
-		utils.JSONError(w, "Failed to create post", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "User", "Failed to create post", "user_id", userID)
 		return
 	}
 
@@ -115,44 +111,49 @@ func (s *Server) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 
 // UpdatePostHandler godoc
 // @Summary      Edit postingan
-// @Description  Mengubah judul atau konten post berdasarkan ID
+// @Description  Mengubah judul atau konten post berdasarkan ID. Setiap perubahan disimpan sebagai revisi baru. Kirim header If-Match berisi ETag dari GET /posts/{id} agar perubahan orang lain tidak tertimpa.
 // @Tags         posts
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "ID Postingan (UUID)"
 // @Param        request body   handlers.UpdatePostInput true "Data Update"
+// @Param        If-Match header string  false "ETag post yang terakhir dibaca"
 // @Security     BearerAuth
-// @Success      200  {object}  handlers.SuccessResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      412  {object}  utils.ErrorResponse
+// @Failure      428  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/posts/{id} [put]
 func (s *Server) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["id"]
 
-	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
-	if !ok || currentUserID == "" {
-		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
-		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+	ownerID, err := s.Posts.GetPostOwnerID(r.Context(), postID)
+	if err != nil {
+		writeStoreError(w, r, err, "Post", "Failed get post", "post_id", postID)
+		return
 	}
 
-	ownerID, err := db.GetPostOwnerID(s.DB, postID)
+	err = policy.Authorize(r.Context(), policy.PostUpdate, policy.Resource{OwnerID: ownerID})
 	if err != nil {
-		slog.WarnContext(r.Context(), "Update failed: Not found post",
-			"error", err,
+		writePolicyError(w, r, err, policy.PostUpdate, "You are not allowed to update this post",
 			"post_id", postID,
+			"target_owner_id", ownerID,
 		)
-		utils.JSONError(w, "Failed get post", http.StatusNotFound)
+		return
+	}
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if currentUserID != ownerID {
-		slog.WarnContext(r.Context(), "Update failed: Forbidden access",
-			"post_id", postID,
-			"attempt_by_user_id", currentUserID,
-			"target_owner_id", ownerID,
-		)
-		utils.JSONError(w, "You are not allowed to update this post", http.StatusForbidden)
+	ifVersion, ok := s.ifMatchVersions(w, r)
+	if !ok {
 		return
 	}
 
@@ -163,13 +164,9 @@ func (s *Server) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.UpdatePostByID(s.DB, input.Title, input.Content, postID)
+	err = s.Posts.UpdatePostByID(r.Context(), input.Title, input.Content, postID, currentUserID, ifVersion)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to update post in DB",
-			"error", err,
-			"post_id", postID,
-		)
-		utils.JSONError(w, "Failed to update post", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Post", "Failed to update post", "post_id", postID)
 		return
 	}
 
@@ -182,52 +179,45 @@ func (s *Server) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
 
 // DeletePostHandler godoc
 // @Summary      Hapus postingan
-// @Description  Menghapus post permanen berdasarkan ID
+// @Description  Memindahkan post ke trash berdasarkan ID. Post bisa dipulihkan sampai masa retensi habis. Moderator dan admin boleh menghapus post siapa pun.
 // @Tags         posts
 // @Produce      json
 // @Param        id   path      string  true  "ID Postingan (UUID)"
 // @Security     BearerAuth
-// @Success      200  {object}  handlers.SuccessResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/posts/{id} [delete]
 func (s *Server) DeletePostHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["id"]
 
-	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
-	if !ok || currentUserID == "" {
-		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
-		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
-	}
-
-	ownerID, err := db.GetPostOwnerID(s.DB, postID)
+	ownerID, err := s.Posts.GetPostOwnerID(r.Context(), postID)
 	if err != nil {
-		slog.WarnContext(r.Context(), "Not found post",
-			"error", err,
-			"post_id", postID,
-		)
-		utils.JSONError(w, "Failed to get post", http.StatusNotFound)
+		writeStoreError(w, r, err, "Post", "Failed to get post", "post_id", postID)
 		return
 	}
 
-	if currentUserID != ownerID {
-		slog.WarnContext(r.Context(), "Invalid user_id",
+	err = policy.Authorize(r.Context(), policy.PostDelete, policy.Resource{OwnerID: ownerID})
+	if err != nil {
+		writePolicyError(w, r, err, policy.PostDelete, "You are not allowed to delete this post",
 			"post_id", postID,
-			"attempt_by_user_id", currentUserID,
 			"target_owner_id", ownerID,
 		)
-		utils.JSONError(w, "Invalid user_id", http.StatusForbidden)
+		return
+	}
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	err = db.DeletePostByID(s.DB, postID)
+	err = s.Posts.DeletePostByID(r.Context(), postID)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed delete post in DB",
-			"error", err,
-			"post_id", postID,
-		)
-		utils.JSONError(w, "Failed to delete post", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Post", "Failed to delete post", "post_id", postID)
 		return
 	}
 
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"gopher-post/middleware"
+	"gopher-post/policy"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetPostRevisionsHandler godoc
+// @Summary      Riwayat revisi postingan
+// @Description  Mengambil semua revisi post, dari yang paling lama
+// @Tags         revisions
+// @Produce      json
+// @Param        id   path      string  true  "ID Postingan (UUID)"
+// @Success      200  {array}   models.PostRevision
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /posts/{id}/revisions [get]
+func (s *Server) GetPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	postID := mux.Vars(r)["id"]
+
+	revisions, err := s.Revisions.GetPostRevisions(r.Context(), postID)
+	if err != nil {
+		writeStoreError(w, r, err, "Post", "Failed get revisions", "post_id", postID)
+		return
+	}
+
+	utils.JSONSuccess(w, revisions, http.StatusOK)
+}
+
+// GetPostRevisionHandler godoc
+// @Summary      Lihat satu revisi
+// @Description  Mengambil isi post pada revisi tertentu
+// @Tags         revisions
+// @Produce      json
+// @Param        id   path      string  true  "ID Postingan (UUID)"
+// @Param        rev  path      int     true  "Nomor revisi"
+// @Success      200  {object}  models.PostRevision
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /posts/{id}/revisions/{rev} [get]
+func (s *Server) GetPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	revision, ok := parseRevision(w, vars["rev"])
+	if !ok {
+		return
+	}
+
+	result, err := s.Revisions.GetPostRevision(r.Context(), postID, revision)
+	if err != nil {
+		writeStoreError(w, r, err, "Revision", "Failed get revision",
+			"post_id", postID,
+			"revision", revision,
+		)
+		return
+	}
+
+	utils.JSONSuccess(w, result, http.StatusOK)
+}
+
+// DiffPostRevisionsHandler godoc
+// @Summary      Bandingkan dua revisi
+// @Description  Menampilkan perbedaan per baris untuk judul dan konten antara dua revisi
+// @Tags         revisions
+// @Produce      json
+// @Param        id    path      string  true  "ID Postingan (UUID)"
+// @Param        from  query     int     true  "Revisi awal"
+// @Param        to    query     int     true  "Revisi tujuan"
+// @Success      200  {object}  utils.RevisionDiffResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /posts/{id}/revisions/diff [get]
+func (s *Server) DiffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	postID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	from, ok := parseRevision(w, query.Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := parseRevision(w, query.Get("to"))
+	if !ok {
+		return
+	}
+
+	oldRevision, err := s.Revisions.GetPostRevision(r.Context(), postID, from)
+	if err != nil {
+		writeStoreError(w, r, err, "Revision", "Failed get revision",
+			"post_id", postID,
+			"revision", from,
+		)
+		return
+	}
+	newRevision, err := s.Revisions.GetPostRevision(r.Context(), postID, to)
+	if err != nil {
+		writeStoreError(w, r, err, "Revision", "Failed get revision",
+			"post_id", postID,
+			"revision", to,
+		)
+		return
+	}
+
+	utils.JSONSuccess(w, utils.RevisionDiffResponse{
+		From:    from,
+		To:      to,
+		Title:   utils.LineDiff(oldRevision.Title, newRevision.Title),
+		Content: utils.LineDiff(oldRevision.Content, newRevision.Content),
+	}, http.StatusOK)
+}
+
+// RollbackPostHandler godoc
+// @Summary      Kembalikan ke revisi lama
+// @Description  Mengembalikan judul dan konten post ke revisi tertentu. Rollback disimpan sebagai revisi baru.
+// @Tags         revisions
+// @Produce      json
+// @Param        id   path      string  true  "ID Postingan (UUID)"
+// @Param        rev  path      int     true  "Nomor revisi"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/posts/{id}/revisions/{rev}/rollback [post]
+func (s *Server) RollbackPostHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	revision, ok := parseRevision(w, vars["rev"])
+	if !ok {
+		return
+	}
+
+	ownerID, err := s.Posts.GetPostOwnerID(r.Context(), postID)
+	if err != nil {
+		writeStoreError(w, r, err, "Post", "Failed get post", "post_id", postID)
+		return
+	}
+
+	err = policy.Authorize(r.Context(), policy.PostRollback, policy.Resource{OwnerID: ownerID})
+	if err != nil {
+		writePolicyError(w, r, err, policy.PostRollback, "You are not allowed to update this post",
+			"post_id", postID,
+			"target_owner_id", ownerID,
+		)
+		return
+	}
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = s.Revisions.RollbackPost(r.Context(), postID, revision, currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "Revision", "Failed to roll back post",
+			"post_id", postID,
+			"revision", revision,
+		)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Post rolled back successfully",
+		"post_id", postID,
+		"revision", revision,
+		"user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "post rolled back"}, http.StatusOK)
+}
+
+// parseRevision reads a positive revision number. It writes a 400 and
+// returns false when raw is not one.
+func parseRevision(w http.ResponseWriter, raw string) (int, bool) {
+	revision, err := strconv.Atoi(raw)
+	if err != nil || revision < 1 {
+		utils.JSONError(w, "Invalid revision", http.StatusBadRequest)
+		return 0, false
+	}
+	return revision, true
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"gopher-post/utils"
+	"net/http"
+	"strings"
+)
+
+// SearchHandler godoc
+// @Summary      Cari postingan dan komentar
+// @Description  Pencarian full-text pada judul/konten postingan dan komentar, diurutkan berdasarkan relevansi. Snippet menandai kata yang cocok dengan <mark>.
+// @Tags         search
+// @Produce      json
+// @Param        q             query    string  true   "Kata kunci (mendukung \"frasa\", OR, dan -kata)"
+// @Param        cursor        query    string  false  "Cursor dari next_cursor / prev_cursor"
+// @Param        limit         query    int     false  "Jumlah Data per Halaman (maks 100)"
+// @Param        include_total query    bool    false  "Sertakan jumlah total data"
+// @Success      200  {object}  utils.PageResponse[models.SearchResult]
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /search [get]
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.JSONError(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		utils.JSONError(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.Search.Search(r.Context(), query, page)
+	if err != nil {
+		writeStoreError(w, r, err, "Search", "Failed search", "query", query)
+		return
+	}
+
+	writePage(w, r, results)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"gopher-post/middleware"
+	"gopher-post/utils"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSessionsHandler godoc
+// @Summary      Lihat sesi aktif
+// @Description  Mengambil semua login user yang masih aktif, yang terakhir dipakai lebih dulu
+// @Tags         sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Session
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/sessions [get]
+func (s *Server) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := s.Sessions.GetSessions(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get sessions", "user_id", currentUserID)
+		return
+	}
+
+	currentSessionID, _ := r.Context().Value(middleware.SessionIDKey).(string)
+	for i := range *sessions {
+		(*sessions)[i].Current = (*sessions)[i].ID == currentSessionID
+	}
+
+	utils.JSONSuccess(w, sessions, http.StatusOK)
+}
+
+// RevokeSessionHandler godoc
+// @Summary      Cabut sesi
+// @Description  Mengakhiri satu sesi, misalnya dari laptop yang hilang. Token dari sesi itu langsung ditolak.
+// @Tags         sessions
+// @Produce      json
+// @Param        id   path      string  true  "ID Sesi (UUID)"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/sessions/{id} [delete]
+func (s *Server) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := s.Sessions.RevokeSession(r.Context(), sessionID, currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "Session", "Failed revoke session",
+			"session_id", sessionID,
+			"user_id", currentUserID,
+		)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Session revoked successfully",
+		"session_id", sessionID,
+		"user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "session revoked"}, http.StatusOK)
+}
+
+// RevokeAllSessionsHandler godoc
+// @Summary      Keluar dari semua perangkat
+// @Description  Mengakhiri semua sesi user, termasuk sesi yang sedang dipakai
+// @Tags         sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/sessions [delete]
+func (s *Server) RevokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := s.Sessions.RevokeAllSessions(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed revoke sessions", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "All sessions revoked",
+		"user_id", currentUserID,
+		"revoked", revoked,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "logged out everywhere"}, http.StatusOK)
+}
+
+// clientIP is the address of the peer that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
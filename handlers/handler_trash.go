@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"gopher-post/db"
+	"gopher-post/middleware"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetTrashHandler godoc
+// @Summary      Lihat trash
+// @Description  Mengambil postingan dan komentar milik user yang sudah dihapus dan belum di-purge
+// @Tags         trash
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.TrashItem
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/trash [get]
+func (s *Server) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := s.Trash.GetTrash(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get trash", "user_id", currentUserID)
+		return
+	}
+
+	utils.JSONSuccess(w, items, http.StatusOK)
+}
+
+// RestorePostHandler godoc
+// @Summary      Pulihkan postingan
+// @Description  Mengembalikan postingan dari trash beserta komentarnya
+// @Tags         trash
+// @Produce      json
+// @Param        id   path      string  true  "ID Postingan (UUID)"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/trash/posts/{id}/restore [post]
+func (s *Server) RestorePostHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["id"]
+
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := s.Trash.RestorePost(r.Context(), postID, currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "Post", "Failed restore post",
+			"post_id", postID,
+			"user_id", currentUserID,
+		)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Post restored successfully",
+		"post_id", postID,
+		"user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "post restored"}, http.StatusOK)
+}
+
+// RestoreCommentHandler godoc
+// @Summary      Pulihkan komentar
+// @Description  Mengembalikan komentar dari trash. Selama post-nya masih di trash, pulihkan post terlebih dahulu.
+// @Tags         trash
+// @Produce      json
+// @Param        id   path      string  true  "ID Komentar (UUID)"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/trash/comments/{id}/restore [post]
+func (s *Server) RestoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentID := vars["id"]
+
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := s.Trash.RestoreComment(r.Context(), commentID, currentUserID)
+	if errors.Is(err, db.ErrConflict) {
+		slog.WarnContext(r.Context(), "Restore comment refused: post is in the trash", "comment_id", commentID)
+		utils.JSONError(w, "The post of this comment is in the trash, restore the post first", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "Comment", "Failed restore comment",
+			"comment_id", commentID,
+			"user_id", currentUserID,
+		)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Comment restored successfully",
+		"comment_id", commentID,
+		"user_id", currentUserID,
+	)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "comment restored"}, http.StatusOK)
+}
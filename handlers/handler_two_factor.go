@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gopher-post/db"
+	"gopher-post/middleware"
+	"gopher-post/models"
+	"gopher-post/totp"
+	"gopher-post/utils"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// recoveryCodeCount is how many recovery codes an enrollment hands out.
+const recoveryCodeCount = 10
+
+// totpSkew accepts codes one time step before or after the current one.
+const totpSkew = 1
+
+// writeMFAChallenge answers a correct password of a user with two-factor
+// authentication with a challenge token instead of a session.
+func (s *Server) writeMFAChallenge(w http.ResponseWriter, r *http.Request, userID string) {
+	token, err := s.Keys.CreateMFAToken(userID, s.MFATokenTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating MFA token", "error", err)
+		utils.JSONError(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Login needs second factor", "user_id", userID)
+	utils.JSONSuccess(w, utils.MFAChallengeResponse{
+		Message:   "mfa required",
+		MFAToken:  token,
+		ExpiresIn: int(s.MFATokenTTL.Seconds()),
+	}, http.StatusAccepted)
+}
+
+// checkSecondFactor verifies a TOTP code or, when code is empty, a recovery
+// code of userID and consumes it. Wrong codes count as failed attempts, and
+// the error response is written here.
+func (s *Server) checkSecondFactor(w http.ResponseWriter, r *http.Request, userID string, code string, recoveryCode string) bool {
+	keys := s.secondFactorKeys(r, userID)
+	if !s.checkAttemptAllowed(w, r, keys) {
+		return false
+	}
+
+	authenticator, err := s.TwoFactor.GetTOTP(r.Context(), userID)
+	if err == nil && !authenticator.Enabled() {
+		err = db.ErrNotFound
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		utils.JSONError(w, "Two-factor authentication is not enabled", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get authenticator", "user_id", userID)
+		return false
+	}
+
+	if code == "" {
+		err = s.TwoFactor.UseRecoveryCode(r.Context(), userID, utils.HashRecoveryCode(recoveryCode))
+		if errors.Is(err, db.ErrNotFound) {
+			slog.WarnContext(r.Context(), "Second factor failed: invalid recovery code", "user_id", userID)
+			s.attemptFailed(w, r, keys, "Invalid code")
+			return false
+		}
+		if err != nil {
+			writeStoreError(w, r, err, "User", "Failed use recovery code", "user_id", userID)
+			return false
+		}
+
+		slog.InfoContext(r.Context(), "Recovery code used", "user_id", userID)
+		s.attemptSucceeded(r, keys)
+		return true
+	}
+
+	counter, ok := totp.Validate(authenticator.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		slog.WarnContext(r.Context(), "Second factor failed: invalid code", "user_id", userID)
+		s.attemptFailed(w, r, keys, "Invalid code")
+		return false
+	}
+
+	err = s.TwoFactor.UseTOTPCounter(r.Context(), userID, counter)
+	if errors.Is(err, db.ErrTokenReused) {
+		slog.WarnContext(r.Context(), "Second factor failed: code replayed", "user_id", userID)
+		s.attemptFailed(w, r, keys, "Code already used, wait for the next one")
+		return false
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed record code", "user_id", userID)
+		return false
+	}
+
+	s.attemptSucceeded(r, keys)
+	return true
+}
+
+// TwoFactorLoginHandler godoc
+// @Summary      Selesaikan login 2FA
+// @Description  Tukar mfa_token dari /login dan kode authenticator (atau recovery code) dengan access token dan refresh token.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.TwoFactorLoginInput true "Token challenge dan kode"
+// @Success      200  {object}  utils.LoginResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      429  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /auth/2fa [post]
+func (s *Server) TwoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var input TwoFactorLoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.MFAToken == "" || (input.Code == "" && input.RecoveryCode == "") {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := s.Keys.ParseMFAToken(input.MFAToken)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Second factor failed: invalid challenge", "error", err)
+		utils.JSONError(w, "Invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.checkSecondFactor(w, r, claims.UserID, input.Code, input.RecoveryCode) {
+		return
+	}
+
+	s.startSession(w, r, claims.UserID, "login successful")
+}
+
+// GetTwoFactorHandler godoc
+// @Summary      Status 2FA
+// @Description  Menampilkan apakah 2FA user yang sedang login aktif.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.TwoFactorStatusResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/2fa [get]
+func (s *Server) GetTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	authenticator, err := s.TwoFactor.GetTOTP(r.Context(), currentUserID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Failed get authenticator", "user_id", currentUserID)
+		return
+	}
+
+	utils.JSONSuccess(w, utils.TwoFactorStatusResponse{Enabled: authenticator.Enabled()}, http.StatusOK)
+}
+
+// EnrollTwoFactorHandler godoc
+// @Summary      Mulai aktivasi 2FA
+// @Description  Membuat secret TOTP baru beserta URI otpauth:// untuk QR code. 2FA baru aktif setelah dikonfirmasi dengan kode lewat POST /api/me/2fa/confirm.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      201  {object}  utils.TwoFactorEnrollResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/2fa [post]
+func (s *Server) EnrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", currentUserID)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating TOTP secret", "error", err)
+		utils.JSONError(w, "Error generating secret", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.TwoFactor.SetTOTPSecret(r.Context(), currentUserID, secret)
+	if errors.Is(err, db.ErrConflict) {
+		utils.JSONError(w, "Two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed store authenticator", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Two-factor enrollment started", "user_id", currentUserID)
+	utils.JSONSuccess(w, utils.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.TOTPIssuer, user.Email, secret),
+	}, http.StatusCreated)
+}
+
+// ConfirmTwoFactorHandler godoc
+// @Summary      Konfirmasi 2FA
+// @Description  Mengaktifkan 2FA dengan kode pertama dari authenticator dan mengembalikan recovery code sekali pakai. Recovery code hanya ditampilkan sekali.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.TwoFactorCodeInput true "Kode dari authenticator"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.RecoveryCodesResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/2fa/confirm [post]
+func (s *Server) ConfirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input TwoFactorCodeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Code == "" {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	authenticator, err := s.TwoFactor.GetTOTP(r.Context(), currentUserID)
+	if errors.Is(err, db.ErrNotFound) {
+		utils.JSONError(w, "No two-factor enrollment in progress", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get authenticator", "user_id", currentUserID)
+		return
+	}
+	if authenticator.Enabled() {
+		utils.JSONError(w, "Two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+
+	counter, ok := totp.Validate(authenticator.Secret, input.Code, time.Now(), totpSkew)
+	if !ok {
+		utils.JSONError(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, hash, err := utils.NewRecoveryCode()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating recovery code", "error", err)
+			utils.JSONError(w, "Error generating recovery codes", http.StatusInternalServerError)
+			return
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+
+	err = s.TwoFactor.ConfirmTOTP(r.Context(), currentUserID, counter, hashes)
+	if errors.Is(err, db.ErrNotFound) {
+		// a concurrent confirmation won
+		utils.JSONError(w, "Two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed confirm authenticator", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Two-factor authentication enabled", "user_id", currentUserID)
+	utils.JSONSuccess(w, utils.RecoveryCodesResponse{
+		Message:       "two-factor authentication enabled, store these recovery codes safely",
+		RecoveryCodes: codes,
+	}, http.StatusOK)
+}
+
+// DisableTwoFactorHandler godoc
+// @Summary      Nonaktifkan 2FA
+// @Description  Menonaktifkan 2FA setelah memasukkan kode authenticator atau recovery code. Moderator dan admin wajib tetap memakai 2FA.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body handlers.TwoFactorCodeInput true "Kode dari authenticator atau recovery code"
+// @Security     BearerAuth
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      401  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Router       /api/me/2fa [delete]
+func (s *Server) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || currentUserID == "" {
+		slog.ErrorContext(r.Context(), "Auth Context missing UserID")
+		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input TwoFactorCodeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		utils.JSONError(w, "Invalid Input", http.StatusBadRequest)
+		return
+	}
+
+	if middleware.HasRole(r.Context(), models.RoleModerator, models.RoleAdmin) {
+		utils.JSONError(w, "Moderators and admins must keep two-factor authentication enabled", http.StatusForbidden)
+		return
+	}
+
+	if !s.checkSecondFactor(w, r, currentUserID, input.Code, input.RecoveryCode) {
+		return
+	}
+
+	err := s.TwoFactor.DeleteTOTP(r.Context(), currentUserID)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed disable two-factor authentication", "user_id", currentUserID)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Two-factor authentication disabled", "user_id", currentUserID)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "two-factor authentication disabled"}, http.StatusOK)
+}
+
+// requireTwoFactor reports whether userID may receive role. Moderation rights
+// are only granted to users with two-factor authentication enabled.
+func (s *Server) requireTwoFactor(w http.ResponseWriter, r *http.Request, userID string, role string) bool {
+	if role == models.RoleUser {
+		return true
+	}
+
+	authenticator, err := s.TwoFactor.GetTOTP(r.Context(), userID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeStoreError(w, r, err, "User", "Failed get authenticator", "user_id", userID)
+		return false
+	}
+	if !authenticator.Enabled() {
+		utils.JSONError(w, "User must enable two-factor authentication first", http.StatusConflict)
+		return false
+	}
+
+	return true
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"gopher-post/db"
-	"gopher-post/middleware"
+	"gopher-post/models"
+	"gopher-post/policy"
 	"gopher-post/utils"
 	"log/slog"
 	"net/http"
@@ -13,45 +16,79 @@ import (
 
 // GetUserAllHandler godoc
 // @Summary      List all users
-// @Description  Retrieves a list of all registered users
+// @Description  Retrieves registered users with cursor pagination, ordered by (created_at, id). pending_email and disabled_at are only shown to the user themself and admins.
 // @Tags         users
 // @Produce      json
+// @Param        cursor        query    string  false  "Cursor from next_cursor / prev_cursor"
+// @Param        limit         query    int     false  "Page size (max 100)"
+// @Param        include_total query    bool    false  "Include the total count"
 // @Security     BearerAuth
-// @Success      200  {array}   models.User
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.PageResponse[models.User]
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/users [get]
 func (s *Server) GetUserAllHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := db.GetUserAll(s.DB)
+	page, err := parsePageRequest(r)
 	if err != nil {
-		utils.JSONError(w, "Database error", http.StatusInternalServerError)
+		utils.JSONError(w, "Invalid cursor", http.StatusBadRequest)
 		return
 	}
 
-	utils.JSONSuccess(w, &users, http.StatusOK)
+	users, err := s.Users.GetUserAll(r.Context(), page)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Database error")
+		return
+	}
+	for i, user := range users.Items {
+		users.Items[i] = visibleUser(r, user)
+	}
+
+	writePage(w, r, users)
 }
 
 // GetUserByIDHandler godoc
 // @Summary      Lihat profil user
-// @Description  Mengambil data detail user berdasarkan ID (UUID)
+// @Description  Mengambil data detail user berdasarkan ID (UUID). pending_email dan disabled_at hanya terlihat oleh user itu sendiri dan admin.
 // @Tags         users
 // @Produce      json
 // @Param        id   path      string  true  "ID User (UUID)"
 // @Security     BearerAuth
 // @Success      200  {object}  models.User
-// @Failure      404  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/users/{id} [get]
 func (s *Server) GetUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	user, err := db.GetUserByID(s.DB, id)
+	user, err := s.Users.GetUserByID(r.Context(), id)
 	if err != nil {
-		utils.JSONError(w, "User not found or database error", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", id)
 		return
 	}
 
-	utils.JSONSuccess(w, &user, http.StatusOK)
+	// the public representation gets its own tag, so it can neither be
+	// revalidated against the private one nor used for If-Match
+	visible, etag := *user, versionETag(user.Version)
+	if !canViewPrivate(r, user) {
+		visible, etag = user.Public(), publicVersionETag(user.Version)
+	}
+	w.Header().Set("ETag", etag)
+	utils.JSONSuccess(w, &visible, http.StatusOK)
+}
+
+// visibleUser hides the private details of user unless the caller is the
+// user or an admin.
+func visibleUser(r *http.Request, user models.User) models.User {
+	if !canViewPrivate(r, &user) {
+		return user.Public()
+	}
+	return user
+}
+
+func canViewPrivate(r *http.Request, user *models.User) bool {
+	return policy.Authorize(r.Context(), policy.UserViewPrivate, policy.Resource{OwnerID: user.ID}) == nil
 }
 
 // CreateUserHandler godoc
@@ -61,10 +98,11 @@ func (s *Server) GetUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        request body handlers.RegisterInput true "Data User"
-// @Success      201  {object}  handlers.SuccessResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      409  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      201  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
+// @Failure      503  {object}  utils.ErrorResponse
 // @Router       /register [post]
 func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input RegisterInput
@@ -75,7 +113,12 @@ func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	exists, err := db.CheckEmailExists(s.DB, input.Email)
+	if !utils.ValidEmail(input.Email) {
+		utils.JSONError(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := s.Users.CheckEmailExists(r.Context(), input.Email)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "Failed check email in DB",
 			"error", err,
@@ -90,43 +133,57 @@ func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	password_hash, err := utils.HashPassword(input.Password)
-	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed hashing password", "error", err)
-		utils.JSONError(w, "Failed hash password", http.StatusInternalServerError)
+	password_hash, ok := s.hashPassword(w, r, input.Password)
+	if !ok {
 		return
 	}
 
-	err = db.CreateUserInDB(s.DB, input.Name, input.Email, password_hash)
+	err = s.Users.CreateUserInDB(r.Context(), input.Name, input.Email, password_hash)
+	if errors.Is(err, db.ErrConflict) {
+		// lost the race against a concurrent registration with the same email
+		utils.JSONError(w, "Email already in use", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed create user in DB",
-			"error", err,
+		writeStoreError(w, r, err, "User", "Failed create user",
 			"name", input.Name,
 			"email", input.Email,
 		)
-		utils.JSONError(w, "Failed create user", http.StatusInternalServerError)
 		return
 	}
 
+	user, err := s.Users.GetUserByEmail(r.Context(), input.Email)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed get created user", "email", input.Email)
+		return
+	}
+	go s.sendEmailVerification(context.WithoutCancel(r.Context()), user.ID, user.Name, user.Email)
+
 	slog.InfoContext(r.Context(), "User created successfully",
 		"name", input.Name,
 		"email", input.Email,
 	)
-	utils.JSONSuccess(w, utils.SuccessResponse{Message: "user created"}, http.StatusCreated)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: "user created, check your email to verify it"}, http.StatusCreated)
 }
 
 // UpdateUserHandler godoc
 // @Summary      Update user profile
-// @Description  Updates the name and email of a user identified by ID.
+// @Description  Updates the name and email of a user identified by ID. A new email only takes effect once it is confirmed through the link mailed to it. Admins may update any user.
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "User ID (UUID)"
 // @Param        request body handlers.UpdateUserInput true "Updated user data"
+// @Param        If-Match header string false "ETag of the user as last read"
 // @Security     BearerAuth
-// @Success      200  {object}  handlers.SuccessResponse
-// @Failure      400  {object}  handlers.ErrorResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      400  {object}  utils.ErrorResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      409  {object}  utils.ErrorResponse
+// @Failure      412  {object}  utils.ErrorResponse
+// @Failure      428  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/users/{id} [put]
 func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input UpdateUserInput
@@ -139,64 +196,92 @@ func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	currentUserID := r.Context().Value(middleware.UserIDKey).(string)
-	if currentUserID != id {
-		slog.WarnContext(r.Context(), "Invalid user",
-			"attempt_by_user_id", currentUserID,
-			"target_owner_id", id,
-		)
-		utils.JSONError(w, "Invalid user", http.StatusForbidden)
+	err = policy.Authorize(r.Context(), policy.UserUpdate, policy.Resource{OwnerID: id})
+	if err != nil {
+		writePolicyError(w, r, err, policy.UserUpdate, "Invalid user", "target_owner_id", id)
+		return
+	}
+
+	ifVersion, ok := s.ifMatchVersions(w, r)
+	if !ok {
+		return
+	}
+
+	if !utils.ValidEmail(input.Email) {
+		utils.JSONError(w, "Invalid email", http.StatusBadRequest)
 		return
 	}
 
-	err = db.UpdateUserByID(s.DB, input.Name, input.Email, id)
+	user, err := s.Users.GetUserByID(r.Context(), id)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed update user in DB",
-			"error", err,
-			"user_id", id)
-		utils.JSONError(w, "Failed update user", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "User", "Failed get user", "user_id", id)
 		return
 	}
 
+	emailChanged := input.Email != user.Email
+	if emailChanged {
+		exists, err := s.Users.CheckEmailExists(r.Context(), input.Email)
+		if err != nil {
+			writeStoreError(w, r, err, "User", "Failed check email", "user_id", id)
+			return
+		}
+		if exists {
+			utils.JSONError(w, "Email already in use", http.StatusConflict)
+			return
+		}
+	}
+
+	err = s.Users.UpdateUserByID(r.Context(), input.Name, input.Email, id, ifVersion)
+	if errors.Is(err, db.ErrConflict) {
+		utils.JSONError(w, "Email already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed update user", "user_id", id)
+		return
+	}
+
+	message := "user updated"
+	if emailChanged {
+		go s.sendEmailVerification(context.WithoutCancel(r.Context()), id, input.Name, input.Email)
+		message = "user updated, confirm the new email to apply it"
+	}
+
 	slog.InfoContext(r.Context(), "User updated successfully",
 		"user_id", id,
+		"email_changed", emailChanged,
 	)
-	utils.JSONSuccess(w, utils.SuccessResponse{Message: "user updated"}, http.StatusOK)
+	utils.JSONSuccess(w, utils.SuccessResponse{Message: message}, http.StatusOK)
 }
 
 // DeleteUserHandler godoc
 // @Summary      Delete user
-// @Description  Deletes a user identified by ID.
+// @Description  Deletes a user identified by ID. Admins may delete any user.
 // @Tags         users
 // @Produce      json
 // @Param        id   path      string  true  "User ID (UUID)"
 // @Security     BearerAuth
-// @Success      200  {object}  handlers.SuccessResponse
-// @Failure      500  {object}  handlers.ErrorResponse
+// @Success      200  {object}  utils.SuccessResponse
+// @Failure      403  {object}  utils.ErrorResponse
+// @Failure      404  {object}  utils.ErrorResponse
+// @Failure      500  {object}  utils.ErrorResponse
 // @Router       /api/users/{id} [delete]
 func (s *Server) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	currentUserID := r.Context().Value(middleware.UserIDKey).(string)
-	if currentUserID != id {
-		slog.WarnContext(r.Context(), "Invalid user",
-			"attempt_by_user_id", currentUserID,
-			"target_owner_id", id,
-		)
-		utils.JSONError(w, "Invalid user", http.StatusForbidden)
+	err := policy.Authorize(r.Context(), policy.UserDelete, policy.Resource{OwnerID: id})
+	if err != nil {
+		writePolicyError(w, r, err, policy.UserDelete, "Invalid user", "target_owner_id", id)
 		return
 	}
 
-	err := db.DeleteUserByID(s.DB, id)
+	err = s.Users.DeleteUserByID(r.Context(), id)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed delete user in DB",
-			"error", err,
-			"user_id", id,
-		)
-		utils.JSONError(w, "Failed delete user", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "User", "Failed delete user", "user_id", id)
 		return
 	}
+	s.TokenVersions.Invalidate(id)
 
 	slog.InfoContext(r.Context(), "User deleted successfully",
 		"user_id", id,
@@ -0,0 +1,1204 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/handlers"
+	"gopher-post/jwtauth"
+	"gopher-post/mailer"
+	"gopher-post/models"
+	"gopher-post/oidc"
+	"gopher-post/oidc/oidctest"
+	"gopher-post/passhash"
+	"gopher-post/routes"
+	"gopher-post/totp"
+	"gopher-post/utils"
+	"gopher-post/workpool"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, configure ...func(*handlers.Server)) *httptest.Server {
+	t.Helper()
+	srv := handlers.NewServer(db.NewMemoryStore(), jwtauth.NewHMACKeySet([]byte("test-secret")))
+	for _, fn := range configure {
+		fn(srv)
+	}
+	ts := httptest.NewServer(routes.SetupRoutes(srv))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func doJSON(t *testing.T, method, url, token string, body any) *http.Response {
+	t.Helper()
+	return doJSONWithHeaders(t, method, url, token, body, nil)
+}
+
+func doJSONWithHeaders(t *testing.T, method, url, token string, body any, headers map[string]string) *http.Response {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, url, &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func registerAndLogin(t *testing.T, baseURL, email string) string {
+	t.Helper()
+
+	resp := doJSON(t, http.MethodPost, baseURL+"/register", "", handlers.RegisterInput{
+		Name: "Gopher", Email: email, Password: "secret123",
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, baseURL+"/login", "", handlers.LoginInput{
+		Email: email, Password: "secret123",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	require.NotEmpty(t, login.Token)
+
+	return login.Token
+}
+
+func TestPostAndCommentFlow(t *testing.T) {
+	ts := newTestServer(t)
+	owner := registerAndLogin(t, ts.URL, "owner@example.com")
+	other := registerAndLogin(t, ts.URL, "other@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", owner, handlers.CreatePostInput{
+		Title: "Halo", Content: "Postingan pertama",
+	})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	require.Len(t, posts.Data, 1)
+	postID := posts.Data[0].ID
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/"+postID+"/comments", other, handlers.CreateCommentInput{
+		Content: "Mantap",
+	})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// user lain tidak boleh mengedit post milik owner
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/posts/"+postID, other, handlers.UpdatePostInput{
+		Title: "Dibajak", Content: "Dibajak",
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/posts/"+postID, owner, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/comments", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var comments utils.PageResponse[models.Comment]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&comments))
+	assert.Empty(t, comments.Data)
+}
+
+func TestRegisterDuplicateEmail(t *testing.T) {
+	ts := newTestServer(t)
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/register", "", handlers.RegisterInput{
+		Name: "Gopher", Email: "gopher@example.com", Password: "secret123",
+	})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestStoreErrorsMapToStatus(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/posts/00000000-0000-0000-0000-000000000000", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/bukan-uuid", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/00000000-0000-0000-0000-000000000000/comments", token, handlers.CreateCommentInput{
+		Content: "Halo",
+	})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/comments/00000000-0000-0000-0000-000000000000", token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPostListPagination(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	for i := range 5 {
+		resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{
+			Title: fmt.Sprintf("Post %d", i), Content: "Konten",
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/posts?limit=2&include_total=true", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+
+	var page utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, 5, *page.Total)
+	assert.Empty(t, page.PrevCursor)
+
+	var titles []string
+	for page.NextCursor != "" {
+		for _, post := range page.Data {
+			titles = append(titles, post.Title)
+		}
+		resp = doJSON(t, http.MethodGet, ts.URL+"/posts?limit=2&cursor="+page.NextCursor, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		page = utils.PageResponse[models.Post]{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	}
+	for _, post := range page.Data {
+		titles = append(titles, post.Title)
+	}
+	assert.Equal(t, []string{"Post 0", "Post 1", "Post 2", "Post 3", "Post 4"}, titles)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts?cursor=rusak", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSearchPagination(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	for i := range 3 {
+		resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{
+			Title: fmt.Sprintf("Golang %d", i), Content: "Belajar golang",
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/search", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	seen := map[string]bool{}
+	next := ""
+	for range 3 {
+		resp = doJSON(t, http.MethodGet, ts.URL+"/search?q=golang&limit=1&cursor="+next, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var page utils.PageResponse[models.SearchResult]
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		require.Len(t, page.Data, 1)
+		seen[page.Data[0].ID] = true
+		next = page.NextCursor
+	}
+	assert.Len(t, seen, 3)
+	assert.Empty(t, next)
+}
+
+func TestTrashRestore(t *testing.T) {
+	ts := newTestServer(t)
+	owner := registerAndLogin(t, ts.URL, "owner@example.com")
+	other := registerAndLogin(t, ts.URL, "other@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", owner, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	postID := posts.Data[0].ID
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/posts/"+postID, owner, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/trash", owner, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var trash []models.TrashItem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trash))
+	require.Len(t, trash, 1)
+
+	// hanya pemilik yang bisa memulihkan
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/trash/posts/"+postID+"/restore", other, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/trash/posts/"+postID+"/restore", owner, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPostRevisions(t *testing.T) {
+	ts := newTestServer(t)
+	owner := registerAndLogin(t, ts.URL, "owner@example.com")
+	other := registerAndLogin(t, ts.URL, "other@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", owner, handlers.CreatePostInput{Title: "Halo", Content: "baris satu\nbaris dua"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	postID := posts.Data[0].ID
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/posts/"+postID, owner, handlers.UpdatePostInput{Title: "Halo", Content: "baris satu\nbaris tiga"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/revisions", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var revisions []models.PostRevision
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&revisions))
+	require.Len(t, revisions, 2)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/revisions/diff?from=1&to=2", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var diff utils.RevisionDiffResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&diff))
+	assert.Equal(t, []utils.DiffLine{
+		{Op: utils.DiffEqual, Text: "baris satu"},
+		{Op: utils.DiffDelete, Text: "baris dua"},
+		{Op: utils.DiffInsert, Text: "baris tiga"},
+	}, diff.Content)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/revisions/diff?from=1&to=x", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// hanya pemilik yang boleh rollback
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/"+postID+"/revisions/1/rollback", other, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/"+postID+"/revisions/1/rollback", owner, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/revisions/3", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var revision models.PostRevision
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&revision))
+	assert.Equal(t, "baris satu\nbaris dua", revision.Content)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/revisions/7", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPostUpdateIfMatch(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	postID := posts.Data[0].ID
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// post selalu divalidasi ulang supaya ETag untuk If-Match tidak basi
+	assert.Equal(t, "public, no-cache", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+	require.Equal(t, `"1"`, etag)
+
+	update := handlers.UpdatePostInput{Title: "Halo", Content: "Isi baru"}
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/posts/"+postID, token, update, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// ETag lama sudah basi setelah update pertama
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/posts/"+postID, token, update, map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/posts/"+postID, token, update, map[string]string{"If-Match": "W/" + etag})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/posts/"+postID, token, update, map[string]string{"If-Match": `"1", "2"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil)
+	assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+}
+
+func TestUserUpdateRequiresIfMatch(t *testing.T) {
+	ts := newTestServer(t, func(srv *handlers.Server) { srv.RequireIfMatch = true })
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/api/users", token, nil)
+	var users utils.PageResponse[models.User]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	userID := users.Data[0].ID
+
+	update := handlers.UpdateUserInput{Name: "Gopher Baru", Email: "gopher@example.com"}
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+userID, token, update)
+	assert.Equal(t, http.StatusPreconditionRequired, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users/"+userID, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/users/"+userID, token, update, map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/users/"+userID, token, update, map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+func TestConditionalGet(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public, no-cache", resp.Header.Get("Cache-Control"))
+	listETag := resp.Header.Get("ETag")
+	require.NotEmpty(t, listETag)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	postID := posts.Data[0].ID
+
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts", "", nil, map[string]string{"If-None-Match": listETag})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, "Authorization", resp.Header.Get("Vary"))
+	// Last-Modified hanya sampai detik, jadi 304 hanya diberikan lewat ETag
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil, map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// setelah post diubah, ETag lama tidak lagi cocok
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/posts/"+postID, token, handlers.UpdatePostInput{Title: "Halo", Content: "Isi baru"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts/"+postID, "", nil, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts", "", nil, map[string]string{"If-None-Match": listETag})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSONWithHeaders(t, http.MethodGet, ts.URL+"/posts/"+postID+"/comments", "", nil, map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ts := newTestServer(t)
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	require.NotEmpty(t, login.RefreshToken)
+	assert.Equal(t, 15*60, login.ExpiresIn)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/refresh", "", handlers.RefreshTokenInput{RefreshToken: login.RefreshToken})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var refreshed utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refreshed))
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", refreshed.Token, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// refresh token lama dipakai ulang: token hasil rotasi ikut dicabut
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/refresh", "", handlers.RefreshTokenInput{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/refresh", "", handlers.RefreshTokenInput{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/logout", "", handlers.RefreshTokenInput{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/refresh", "", handlers.RefreshTokenInput{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSessionRevocation(t *testing.T) {
+	ts := newTestServer(t)
+	laptop := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var phone utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&phone))
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", phone.Token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var sessions []models.Session
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sessions))
+	require.Len(t, sessions, 2)
+
+	var laptopSessionID string
+	for _, session := range sessions {
+		if !session.Current {
+			laptopSessionID = session.ID
+		}
+	}
+	require.NotEmpty(t, laptopSessionID)
+
+	// laptop hilang: dicabut dari ponsel, token laptop langsung ditolak
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/me/sessions/"+laptopSessionID, phone.Token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", laptop, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", phone.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/me/sessions", phone.Token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", phone.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/refresh", "", handlers.RefreshTokenInput{RefreshToken: phone.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestTokensRevokedOnPasswordChangeAndDelete(t *testing.T) {
+	ts := newTestServer(t)
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	resp := doJSON(t, http.MethodPut, ts.URL+"/api/me/password", token, handlers.ChangePasswordInput{CurrentPassword: "salah", NewPassword: "baru12345"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/me/password", token, handlers.ChangePasswordInput{CurrentPassword: "secret123", NewPassword: "baru12345"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "baru12345"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users", login.Token, nil)
+	var users utils.PageResponse[models.User]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	userID := users.Data[0].ID
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/users/"+userID, login.Token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// akun sudah dihapus: token lama tidak boleh membuat post
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", login.Token, handlers.CreatePostInput{Title: "Hantu", Content: "Isi"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestJWKSHidesHMACSecret(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/.well-known/jwks.json", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var jwks jwtauth.JWKSet
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	assert.Empty(t, jwks.Keys)
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	var srv *handlers.Server
+	ts := newTestServer(t, func(s *handlers.Server) { srv = s })
+	author := registerAndLogin(t, ts.URL, "author@example.com")
+	registerAndLogin(t, ts.URL, "mod@example.com")
+	registerAndLogin(t, ts.URL, "admin@example.com")
+
+	require.NoError(t, srv.Users.SetUserRoleByEmail(t.Context(), "mod@example.com", models.RoleModerator))
+	require.NoError(t, srv.Users.SetUserRoleByEmail(t.Context(), "admin@example.com", models.RoleAdmin))
+	// login ulang supaya token membawa role baru
+	moderator := registerAndLoginAgain(t, ts.URL, "mod@example.com")
+	admin := registerAndLoginAgain(t, ts.URL, "admin@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", author, handlers.CreatePostInput{Title: "Spam", Content: "Isi"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	authorID := posts.Data[0].UserID
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/posts/"+posts.Data[0].ID, moderator, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// hanya admin yang boleh mengelola user
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+authorID+"/role", moderator, handlers.SetRoleInput{Role: models.RoleAdmin})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+authorID+"/role", admin, handlers.SetRoleInput{Role: "root"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+authorID, moderator, handlers.UpdateUserInput{Name: "Diubah Moderator", Email: "author@example.com"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/users/"+authorID, moderator, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+authorID, admin, handlers.UpdateUserInput{Name: "Diubah Admin", Email: "author@example.com"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+authorID+"/disabled", admin, handlers.SetDisabledInput{Disabled: true})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", author, handlers.CreatePostInput{Title: "Lagi", Content: "Isi"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "author@example.com", Password: "secret123"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+// registerAndLoginAgain logs an already registered user in and returns the
+// new access token.
+func registerAndLoginAgain(t *testing.T, baseURL, email string) string {
+	t.Helper()
+
+	resp := doJSON(t, http.MethodPost, baseURL+"/login", "", handlers.LoginInput{Email: email, Password: "secret123"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var login utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	return login.Token
+}
+
+func TestPostAuthorDeletesComments(t *testing.T) {
+	ts := newTestServer(t)
+	author := registerAndLogin(t, ts.URL, "author@example.com")
+	commenter := registerAndLogin(t, ts.URL, "commenter@example.com")
+	stranger := registerAndLogin(t, ts.URL, "stranger@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/posts", author, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	postID := posts.Data[0].ID
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/"+postID+"/comments", commenter, handlers.CreateCommentInput{Content: "Spam"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts/"+postID+"/comments", "", nil)
+	var comments utils.PageResponse[models.Comment]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&comments))
+	commentID := comments.Data[0].ID
+
+	// orang lain tidak boleh, penulis post boleh
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/comments/"+commentID, stranger, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/comments/"+commentID, author, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// chanMailer hands sent messages to the test, mails are sent in the
+// background.
+type chanMailer chan mailer.Message
+
+func (m chanMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+// waitForMail returns the next mail to to whose subject contains subject,
+// skipping other mails.
+func waitForMail(t *testing.T, sent chanMailer, to string, subject string) mailer.Message {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-sent:
+			if msg.To == to && strings.Contains(msg.Subject, subject) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("no %q mail to %s", subject, to)
+		}
+	}
+}
+
+// mailLink extracts the token of the link to path from msg.
+func mailLink(t *testing.T, msg mailer.Message, path string) string {
+	t.Helper()
+
+	_, link, found := strings.Cut(msg.Body, path+"?token=")
+	require.True(t, found, "mail has no %s link", path)
+	token, _, _ := strings.Cut(link, "\n")
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	sent := make(chanMailer, 10)
+	ts := newTestServer(t, func(s *handlers.Server) {
+		s.Mailer = sent
+		s.PublicURL = "https://gopherpost.example"
+	})
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+	pat := createPersonalToken(t, ts.URL, token)
+
+	// respons untuk email tidak terdaftar harus sama persis
+	resp := doJSON(t, http.MethodPost, ts.URL+"/auth/forgot-password", "", handlers.ForgotPasswordInput{Email: "nobody@example.com"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	unknownBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/forgot-password", "", handlers.ForgotPasswordInput{Email: "gopher@example.com"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	knownBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, unknownBody, knownBody)
+
+	msg := waitForMail(t, sent, "gopher@example.com", "Reset")
+	resetToken := mailLink(t, msg, "https://gopherpost.example/reset-password")
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/reset-password", "", handlers.ResetPasswordInput{Token: resetToken, NewPassword: "rahasia-baru"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// token sekali pakai
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/reset-password", "", handlers.ResetPasswordInput{Token: resetToken, NewPassword: "lagi"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", pat, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "rahasia-baru"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case msg := <-sent:
+		assert.NotContains(t, msg.Subject, "Reset", "unexpected reset mail to %s", msg.To)
+	default:
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	sent := make(chanMailer, 10)
+	ts := newTestServer(t, func(s *handlers.Server) {
+		s.Mailer = sent
+		s.RequireVerifiedEmail = true
+	})
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/register", "", handlers.RegisterInput{Name: "Gopher", Email: "bukan-email", Password: "secret123"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users", token, nil)
+	var users utils.PageResponse[models.User]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	user := users.Data[0]
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	// belum terverifikasi, tidak boleh posting
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	verifyToken := mailLink(t, waitForMail(t, sent, "gopher@example.com", "Confirm"), "/verify-email")
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/verify-email", "", handlers.VerifyEmailInput{Token: verifyToken})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", token, handlers.CreatePostInput{Title: "Halo", Content: "Isi"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/email/verification", token, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// email baru baru berlaku setelah dikonfirmasi
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+user.ID, token, handlers.UpdateUserInput{Name: "Gopher", Email: "baru@example.com"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users/"+user.ID, token, nil)
+	var profile models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+	assert.Equal(t, "gopher@example.com", profile.Email)
+	assert.Equal(t, "baru@example.com", profile.PendingEmail)
+	privateETag := resp.Header.Get("ETag")
+
+	// user lain tidak boleh melihat email yang belum dikonfirmasi
+	other := registerAndLogin(t, ts.URL, "other@example.com")
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users/"+user.ID, other, nil)
+	var public models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&public))
+	assert.Equal(t, "gopher@example.com", public.Email)
+	assert.Empty(t, public.PendingEmail)
+
+	// representasi publik punya ETag sendiri dan tidak bisa dipakai untuk If-Match
+	publicETag := resp.Header.Get("ETag")
+	assert.NotEqual(t, privateETag, publicETag)
+	resp = doJSONWithHeaders(t, http.MethodPut, ts.URL+"/api/users/"+user.ID, token, handlers.UpdateUserInput{Name: "Gopher", Email: "baru@example.com"}, map[string]string{"If-Match": publicETag})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users", other, nil)
+	users = utils.PageResponse[models.User]{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	for _, listed := range users.Data {
+		assert.Empty(t, listed.PendingEmail, listed.Email)
+	}
+
+	changeToken := mailLink(t, waitForMail(t, sent, "baru@example.com", "Confirm"), "/verify-email")
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/verify-email", "", handlers.VerifyEmailInput{Token: changeToken})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users/"+user.ID, token, nil)
+	profile = models.User{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+	assert.Equal(t, "baru@example.com", profile.Email)
+	assert.Empty(t, profile.PendingEmail)
+
+	// link untuk email lama tidak bisa dipakai lagi setelah email berganti
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/verify-email", "", handlers.VerifyEmailInput{Token: verifyToken})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/verify-email", "", handlers.VerifyEmailInput{Token: token})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	var srv *handlers.Server
+	ts := newTestServer(t, func(s *handlers.Server) { srv = s })
+	token := registerAndLogin(t, ts.URL, "gopher@example.com")
+	admin := registerAndLogin(t, ts.URL, "admin@example.com")
+	require.NoError(t, srv.Users.SetUserRoleByEmail(t.Context(), "admin@example.com", models.RoleAdmin))
+	admin = registerAndLoginAgain(t, ts.URL, "admin@example.com")
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/api/users", token, nil)
+	var users utils.PageResponse[models.User]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	userID := users.Data[0].ID
+
+	// moderator wajib memakai 2FA
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+userID+"/role", admin, handlers.SetRoleInput{Role: models.RoleModerator})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/2fa", token, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var enroll utils.TwoFactorEnrollResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&enroll))
+	assert.Contains(t, enroll.OTPAuthURI, "otpauth://totp/")
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/2fa/confirm", token, handlers.TwoFactorCodeInput{Code: "000000x"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	now := totp.Counter(time.Now())
+	code, err := totp.Code(enroll.Secret, now)
+	require.NoError(t, err)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/2fa/confirm", token, handlers.TwoFactorCodeInput{Code: code})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var recovery utils.RecoveryCodesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&recovery))
+	require.Len(t, recovery.RecoveryCodes, 10)
+
+	// password saja tidak cukup lagi
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var challenge utils.MFAChallengeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&challenge))
+	require.NotEmpty(t, challenge.MFAToken)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", challenge.MFAToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// kode yang sudah dipakai untuk konfirmasi tidak bisa diulang
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/2fa", "", handlers.TwoFactorLoginInput{MFAToken: challenge.MFAToken, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	next, err := totp.Code(enroll.Secret, now+1)
+	require.NoError(t, err)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/2fa", "", handlers.TwoFactorLoginInput{MFAToken: challenge.MFAToken, Code: next})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	assert.NotEmpty(t, login.Token)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/2fa", "", handlers.TwoFactorLoginInput{MFAToken: challenge.MFAToken, RecoveryCode: strings.ToUpper(recovery.RecoveryCodes[0])})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/2fa", "", handlers.TwoFactorLoginInput{MFAToken: challenge.MFAToken, RecoveryCode: recovery.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/users/"+userID+"/role", admin, handlers.SetRoleInput{Role: models.RoleModerator})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// moderator tidak boleh mematikan 2FA
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/2fa", "", handlers.TwoFactorLoginInput{MFAToken: challenge.MFAToken, RecoveryCode: recovery.RecoveryCodes[1]})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var moderator utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&moderator))
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/me/2fa", moderator.Token, handlers.TwoFactorCodeInput{RecoveryCode: recovery.RecoveryCodes[2]})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestLoginLockout(t *testing.T) {
+	ts := newTestServer(t)
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	for _, email := range []string{"gopher@example.com", "nobody@example.com"} {
+		// beberapa salah ketik masih dibiarkan tanpa jeda
+		for range handlers.DefaultAccountLockout.FreeFailures {
+			resp := doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: email, Password: "salah"})
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Retry-After"))
+		}
+
+		resp := doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: email, Password: "salah"})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+		// password benar pun ditolak selama masih harus menunggu
+		resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: email, Password: "secret123"})
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, email)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	}
+
+	// akun lain dari IP yang sama tidak ikut terkunci
+	registerAndLogin(t, ts.URL, "other@example.com")
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	var srv *handlers.Server
+	ts := newTestServer(t, func(s *handlers.Server) { srv = s })
+
+	// user lama dengan hash bcrypt dari sebelum argon2id
+	legacyHash, err := passhash.Bcrypt{Cost: 4}.Hash("secret123")
+	require.NoError(t, err)
+	require.NoError(t, srv.Users.CreateUserInDB(t.Context(), "Gopher", "gopher@example.com", legacyHash))
+
+	before, err := srv.Users.GetUserByEmail(t.Context(), "gopher@example.com")
+	require.NoError(t, err)
+
+	registerAndLoginAgain(t, ts.URL, "gopher@example.com")
+	user, err := srv.Users.GetUserByEmail(t.Context(), "gopher@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"), user.PasswordHash)
+	assert.False(t, utils.PasswordNeedsRehash(user.PasswordHash))
+	// rehash bukan ganti password, token yang sudah ada tetap berlaku
+	assert.Equal(t, before.TokenVersion, user.TokenVersion)
+
+	registerAndLoginAgain(t, ts.URL, "gopher@example.com")
+}
+
+func TestPasswordPoolOverload(t *testing.T) {
+	pool := workpool.New(1, 0)
+	ts := newTestServer(t, func(s *handlers.Server) { s.PasswordPool = pool })
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	// satu-satunya worker sedang sibuk
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go pool.Do(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	resp = doJSON(t, http.MethodPost, ts.URL+"/register", "", handlers.RegisterInput{Name: "Gopher", Email: "other@example.com", Password: "secret123"})
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// endpoint lain tetap jalan
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// token reset palsu ditolak sebelum sempat memakai pool
+	resp = doJSON(t, http.MethodPost, ts.URL+"/auth/reset-password", "", handlers.ResetPasswordInput{Token: "palsu", NewPassword: "rahasia-baru"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	close(release)
+	registerAndLoginAgain(t, ts.URL, "gopher@example.com")
+	assert.EqualValues(t, 2, pool.Stats().Rejected)
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	ts := newTestServer(t)
+	login := registerAndLogin(t, ts.URL, "bot@example.com")
+
+	resp := doJSON(t, http.MethodPost, ts.URL+"/api/me/tokens", login, handlers.CreatePersonalTokenInput{Name: "CI", Scopes: []string{"admin"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/tokens", login, handlers.CreatePersonalTokenInput{Name: "CI", Scopes: []string{models.ScopePostsWrite}, ExpiresInDays: 1000})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/tokens", login, handlers.CreatePersonalTokenInput{Name: "CI", Scopes: []string{models.ScopePostsWrite}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created utils.PersonalTokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	pat := created.Token
+	assert.True(t, strings.HasPrefix(pat, models.PersonalTokenPrefix), pat)
+	assert.Equal(t, []string{models.ScopePostsWrite}, created.PersonalToken.Scopes)
+
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", pat, handlers.CreatePostInput{Title: "Rilis", Content: "Dari CI"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/posts", "", nil)
+	var posts utils.PageResponse[models.Post]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&posts))
+	require.Len(t, posts.Data, 1)
+
+	// scope lain dan route khusus login ditolak
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts/"+posts.Data[0].ID+"/comments", pat, handlers.CreateCommentInput{Content: "Bot"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/me/tokens", pat, handlers.CreatePersonalTokenInput{Name: "Lagi", Scopes: models.Scopes})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/users", pat, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/tokens", login, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokens []models.PersonalToken
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "CI", tokens[0].Name)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	resp = doJSON(t, http.MethodDelete, ts.URL+"/api/me/tokens/"+tokens[0].ID, login, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/posts/"+posts.Data[0].ID, pat, handlers.UpdatePostInput{Title: "Lagi", Content: "Lagi"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// ganti password ikut mencabut semua personal token
+	pat = createPersonalToken(t, ts.URL, login)
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/me/password", login, handlers.ChangePasswordInput{CurrentPassword: "secret123", NewPassword: "baru12345"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = doJSON(t, http.MethodPost, ts.URL+"/api/posts", pat, handlers.CreatePostInput{Title: "Rilis", Content: "Dari CI"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// createPersonalToken creates a posts:write token with login and returns it.
+func createPersonalToken(t *testing.T, baseURL, login string) string {
+	t.Helper()
+
+	resp := doJSON(t, http.MethodPost, baseURL+"/api/me/tokens", login, handlers.CreatePersonalTokenInput{Name: "CI", Scopes: []string{models.ScopePostsWrite}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created utils.PersonalTokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	return created.Token
+}
+
+// oidcLogin menjalankan login OIDC dari awal sampai callback sebagai identity
+func oidcLogin(t *testing.T, baseURL string, idp *oidctest.Provider, identity oidctest.Identity) *http.Response {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(baseURL + "/auth/oidc/stub/login")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := idp.Authorize(resp.Header.Get("Location"), identity)
+	require.NoError(t, err)
+
+	resp, err = client.Get(callback)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := oidctest.NewProvider("gopher-post", "rahasia")
+	t.Cleanup(idp.Close)
+
+	var srv *handlers.Server
+	ts := newTestServer(t, func(s *handlers.Server) { srv = s })
+	srv.OIDCProviders = map[string]*oidc.Provider{
+		"stub": oidc.NewProvider(idp.Config("stub", ts.URL+"/auth/oidc/stub/callback"), nil),
+	}
+	userIDOf := func(resp *http.Response) string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var login utils.LoginResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+		claims, err := srv.Keys.ParseAccessToken(login.Token)
+		require.NoError(t, err)
+		return claims.UserID
+	}
+
+	// akun baru dibuat dengan email yang sudah terverifikasi
+	newcomer := oidctest.Identity{Subject: "1", Email: "new@example.com", EmailVerified: true, Name: "Pendatang"}
+	newID := userIDOf(oidcLogin(t, ts.URL, idp, newcomer))
+	user, err := srv.Users.GetUserByID(t.Context(), newID)
+	require.NoError(t, err)
+	assert.Equal(t, "Pendatang", user.Name)
+	assert.NotNil(t, user.EmailVerifiedAt)
+	assert.Equal(t, newID, userIDOf(oidcLogin(t, ts.URL, idp, newcomer)))
+
+	// user lama dengan email terverifikasi ditautkan, bukan diduplikasi
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+	existing, err := srv.Users.GetUserByEmail(t.Context(), "gopher@example.com")
+	require.NoError(t, err)
+	require.NoError(t, srv.Users.VerifyEmail(t.Context(), existing.ID, "gopher@example.com"))
+	gopher := oidctest.Identity{Subject: "2", Email: "gopher@example.com", EmailVerified: true}
+	assert.Equal(t, existing.ID, userIDOf(oidcLogin(t, ts.URL, idp, gopher)))
+	// setelah tertaut, email di provider boleh berubah
+	gopher.Email = "gopher@provider.example"
+	assert.Equal(t, existing.ID, userIDOf(oidcLogin(t, ts.URL, idp, gopher)))
+
+	// email lokal yang belum diverifikasi tidak boleh diambil alih
+	registerAndLogin(t, ts.URL, "victim@example.com")
+	resp := oidcLogin(t, ts.URL, idp, oidctest.Identity{Subject: "3", Email: "victim@example.com", EmailVerified: true})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = oidcLogin(t, ts.URL, idp, oidctest.Identity{Subject: "4", Email: "unverified@example.com"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// akun tanpa password tidak bisa login dengan password apa pun
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "new@example.com", Password: "!"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	idp := oidctest.NewProvider("gopher-post", "rahasia")
+	t.Cleanup(idp.Close)
+	ts := newTestServer(t, func(s *handlers.Server) {
+		s.OIDCProviders = map[string]*oidc.Provider{"stub": oidc.NewProvider(idp.Config("stub", "http://gopherpost.example/callback"), nil)}
+	})
+
+	resp := doJSON(t, http.MethodGet, ts.URL+"/auth/oidc/lain/login", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// tanpa cookie dari /login, callback ditolak
+	resp = doJSON(t, http.MethodGet, ts.URL+"/auth/oidc/stub/callback?code=abc&state=xyz", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// cookie dari login lain dengan state berbeda
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(ts.URL + "/auth/oidc/stub/login")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	cookies := resp.Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/auth/oidc/stub/callback?code=abc&state=palsu", nil)
+	require.NoError(t, err)
+	req.AddCookie(cookies[0])
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	sent := make(chanMailer, 10)
+	var srv *handlers.Server
+	ts := newTestServer(t, func(s *handlers.Server) {
+		srv = s
+		s.Mailer = sent
+		s.PublicURL = "https://gopherpost.example"
+	})
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+	requestLink := func(email string) []byte {
+		t.Helper()
+		resp := doJSON(t, http.MethodPost, ts.URL+"/auth/magic-link", "", handlers.MagicLinkInput{Email: email})
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return body
+	}
+	useLink := func(token string) *http.Response {
+		t.Helper()
+		return doJSON(t, http.MethodPost, ts.URL+"/auth/magic-link/login", "", handlers.MagicLinkLoginInput{Token: token})
+	}
+
+	// respons untuk email tidak terdaftar harus sama persis
+	assert.Equal(t, requestLink("nobody@example.com"), requestLink("gopher@example.com"))
+	linkToken := mailLink(t, waitForMail(t, sent, "gopher@example.com", "login link"), "https://gopherpost.example/magic-link")
+
+	resp := useLink(linkToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login utils.LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	resp = doJSON(t, http.MethodGet, ts.URL+"/api/me/sessions", login.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// membuka link membuktikan email milik user
+	user, err := srv.Users.GetUserByEmail(t.Context(), "gopher@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// link sekali pakai
+	assert.Equal(t, http.StatusUnauthorized, useLink(linkToken).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, useLink("bukan-token").StatusCode)
+
+	// link baru menggantikan link lama
+	requestLink("gopher@example.com")
+	oldLink := mailLink(t, waitForMail(t, sent, "gopher@example.com", "login link"), "https://gopherpost.example/magic-link")
+	requestLink("gopher@example.com")
+	newLink := mailLink(t, waitForMail(t, sent, "gopher@example.com", "login link"), "https://gopherpost.example/magic-link")
+	assert.Equal(t, http.StatusUnauthorized, useLink(oldLink).StatusCode)
+
+	// setelah opt out, link yang sudah terkirim tidak berlaku dan tidak ada link baru
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/me/magic-link", login.Token, handlers.SetMagicLinkInput{Enabled: false})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, useLink(newLink).StatusCode)
+	requestLink("gopher@example.com")
+
+	resp = doJSON(t, http.MethodPut, ts.URL+"/api/me/magic-link", login.Token, handlers.SetMagicLinkInput{Enabled: true})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	requestLink("gopher@example.com")
+	msg := waitForMail(t, sent, "gopher@example.com", "login link")
+	assert.Equal(t, http.StatusOK, useLink(mailLink(t, msg, "https://gopherpost.example/magic-link")).StatusCode)
+
+	select {
+	case msg := <-sent:
+		t.Errorf("unexpected mail %q to %s", msg.Subject, msg.To)
+	default:
+	}
+}
+
+func TestMagicLinkThrottleKeepsPasswordLogin(t *testing.T) {
+	sent := make(chanMailer, 10)
+	ts := newTestServer(t, func(s *handlers.Server) { s.Mailer = sent })
+	registerAndLogin(t, ts.URL, "gopher@example.com")
+
+	// setiap link yang diminta dihitung, sampai email itu harus menunggu
+	for range handlers.DefaultAccountLockout.FreeFailures + 1 {
+		resp := doJSON(t, http.MethodPost, ts.URL+"/auth/magic-link", "", handlers.MagicLinkInput{Email: "gopher@example.com"})
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+	resp := doJSON(t, http.MethodPost, ts.URL+"/auth/magic-link", "", handlers.MagicLinkInput{Email: "Gopher@Example.com"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// permintaan link atas nama korban tidak boleh mengunci login password-nya
+	resp = doJSON(t, http.MethodPost, ts.URL+"/login", "", handlers.LoginInput{Email: "gopher@example.com", Password: "secret123"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
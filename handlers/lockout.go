@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"gopher-post/lockout"
+	"gopher-post/utils"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limitedKey is a key tracked by one of the login limiters.
+type limitedKey struct {
+	limiter *lockout.Limiter
+	key     string
+}
+
+// loginKeys tracks password guesses per account and per client IP.
+// Accounts are keyed by the email as typed, so unknown emails are limited
+// exactly like registered ones.
+func (s *Server) loginKeys(r *http.Request, email string) []limitedKey {
+	return []limitedKey{
+		{s.AccountLockout, "account:" + strings.ToLower(strings.TrimSpace(email))},
+		{s.IPLockout, "ip:" + clientIP(r)},
+	}
+}
+
+// magicLinkRequestKeys throttles login link mails per address and per client
+// IP. The address has its own key, so requests naming someone's email cannot
+// lock their password login.
+func (s *Server) magicLinkRequestKeys(r *http.Request, email string) []limitedKey {
+	return []limitedKey{
+		{s.AccountLockout, "magic:" + strings.ToLower(strings.TrimSpace(email))},
+		{s.IPLockout, "ip:" + clientIP(r)},
+	}
+}
+
+// magicLinkKeys tracks uses of mailed login links per client IP. The links
+// are signed, so there is no account to guess for.
+func (s *Server) magicLinkKeys(r *http.Request) []limitedKey {
+	return []limitedKey{
+		{s.IPLockout, "ip:" + clientIP(r)},
+	}
+}
+
+// secondFactorKeys tracks code guesses of a login waiting for its second
+// factor.
+func (s *Server) secondFactorKeys(r *http.Request, userID string) []limitedKey {
+	return []limitedKey{
+		{s.AccountLockout, "mfa:" + userID},
+		{s.IPLockout, "ip:" + clientIP(r)},
+	}
+}
+
+// checkAttemptAllowed answers 429 Too Many Requests and returns false while
+// any of keys has to wait.
+func (s *Server) checkAttemptAllowed(w http.ResponseWriter, r *http.Request, keys []limitedKey) bool {
+	var wait time.Duration
+	for _, k := range keys {
+		retryAfter, err := k.limiter.RetryAfter(r.Context(), k.key)
+		if err != nil {
+			writeStoreError(w, r, err, "User", "Failed check login attempts")
+			return false
+		}
+		wait = max(wait, retryAfter)
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	slog.WarnContext(r.Context(), "Login refused: too many failed attempts", "retry_after", wait.String())
+	setRetryAfter(w, wait)
+	utils.JSONError(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+	return false
+}
+
+// attemptFailed records a failed attempt for keys and answers 401 with
+// message. Retry-After tells the client when the next attempt is accepted.
+func (s *Server) attemptFailed(w http.ResponseWriter, r *http.Request, keys []limitedKey, message string) {
+	wait, err := s.recordAttempt(r, keys)
+	if err != nil {
+		writeStoreError(w, r, err, "User", "Failed record login attempt")
+		return
+	}
+
+	if wait > 0 {
+		setRetryAfter(w, wait)
+	}
+	utils.JSONError(w, message, http.StatusUnauthorized)
+}
+
+// recordAttempt counts an attempt against keys and returns how long the next
+// one has to wait.
+func (s *Server) recordAttempt(r *http.Request, keys []limitedKey) (time.Duration, error) {
+	var wait time.Duration
+	for _, k := range keys {
+		retryAfter, err := k.limiter.Fail(r.Context(), k.key)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, retryAfter)
+	}
+	return wait, nil
+}
+
+// attemptSucceeded forgets the failures of the account or login keys. The
+// IP stays counted, or one valid account would reset it for any guesses.
+func (s *Server) attemptSucceeded(r *http.Request, keys []limitedKey) {
+	k := keys[0]
+	if err := k.limiter.Reset(r.Context(), k.key); err != nil {
+		slog.ErrorContext(r.Context(), "Failed reset login attempts", "error", err)
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// dummyPasswordHash is compared against for unknown emails, so a login takes
+// as long whether or not the email is registered.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := utils.HashPassword("gopher-post-dummy-password")
+	if err != nil {
+		slog.Error("Failed hashing dummy password", "error", err)
+	}
+	return hash
+})
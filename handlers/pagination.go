@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/utils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parsePageRequest reads the limit, cursor and include_total query
+// parameters shared by every listing endpoint.
+func parsePageRequest(r *http.Request) (db.PageRequest, error) {
+	queryParams := r.URL.Query()
+
+	limit, _ := strconv.Atoi(queryParams.Get("limit"))
+	if limit < 1 {
+		limit = db.DefaultPageLimit
+	}
+	if limit > db.MaxPageLimit {
+		limit = db.MaxPageLimit
+	}
+
+	page := db.PageRequest{Limit: limit}
+	page.WithTotal, _ = strconv.ParseBool(queryParams.Get("include_total"))
+
+	if raw := queryParams.Get("cursor"); raw != "" {
+		cursor, err := db.DecodeCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+
+	return page, nil
+}
+
+// writePage sends page in the standard envelope and advertises the
+// neighbouring pages with RFC 8288 Link headers.
+func writePage[T any](w http.ResponseWriter, r *http.Request, page *db.Page[T]) {
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, page.NextCursor)))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, page.PrevCursor)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	utils.JSONSuccess(w, utils.PageResponse[T]{
+		Data:       page.Items,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Total:      page.Total,
+	}, http.StatusOK)
+}
+
+// pageURL returns the current request URL pointing at cursor, keeping the
+// other query parameters.
+func pageURL(r *http.Request, cursor string) string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"gopher-post/utils"
+	"gopher-post/workpool"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// hashPassword hashes password on the password pool. It writes the error
+// response itself and returns false when the hash could not be computed.
+func (s *Server) hashPassword(w http.ResponseWriter, r *http.Request, password string) (string, bool) {
+	var hash string
+	var hashErr error
+	if err := s.PasswordPool.Do(r.Context(), func() { hash, hashErr = utils.HashPassword(password) }); err != nil {
+		writePoolError(w, r, err)
+		return "", false
+	}
+	if hashErr != nil {
+		slog.ErrorContext(r.Context(), "Failed hashing password", "error", hashErr)
+		utils.JSONError(w, "Failed hash password", http.StatusInternalServerError)
+		return "", false
+	}
+
+	return hash, true
+}
+
+// checkPassword compares password with hash on the password pool. ok is
+// false when the response has already been written because the pool was
+// busy.
+func (s *Server) checkPassword(w http.ResponseWriter, r *http.Request, password string, hash string) (match bool, ok bool) {
+	if err := s.PasswordPool.Do(r.Context(), func() { match = utils.CheckPasswordHash(password, hash) }); err != nil {
+		writePoolError(w, r, err)
+		return false, false
+	}
+
+	return match, true
+}
+
+// writePoolError answers 503 Service Unavailable with Retry-After when the
+// password pool is overloaded.
+func writePoolError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		slog.InfoContext(r.Context(), "Request cancelled by client", "error", err)
+		return
+	}
+
+	if errors.Is(err, workpool.ErrFull) {
+		slog.WarnContext(r.Context(), "Password pool full, request refused")
+	} else {
+		slog.WarnContext(r.Context(), "Timed out waiting for password pool", "error", err)
+	}
+	setRetryAfter(w, time.Second)
+	utils.JSONError(w, "Server busy, please retry", http.StatusServiceUnavailable)
+}
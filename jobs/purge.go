@@ -0,0 +1,55 @@
+// Package jobs holds background tasks started by main alongside the HTTP
+// server.
+package jobs
+
+import (
+	"context"
+	"gopher-post/db"
+	"log/slog"
+	"time"
+)
+
+// RunTrashPurge permanently removes trashed posts and comments older than
+// retention, checking every interval until ctx is cancelled. Running it on
+// several instances at once is harmless.
+func RunTrashPurge(ctx context.Context, store db.TrashStore, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.ErrorContext(ctx, "Trash purge failed", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "Trash purged", "purged", purged, "retention", retention.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunLoginAttemptPurge forgets failed logins older than retention that no
+// longer lock anything, checking every interval until ctx is cancelled.
+func RunLoginAttemptPurge(ctx context.Context, store db.AttemptStore, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.PurgeLoginAttempts(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.ErrorContext(ctx, "Login attempt purge failed", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "Login attempts purged", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
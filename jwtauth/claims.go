@@ -0,0 +1,204 @@
+package jwtauth
+
+import (
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessClaims are the claims of a GopherPost access token.
+type AccessClaims struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"`
+	Role      string `json:"role"`
+	// TokenVersion must equal the user's current token version for the
+	// token to be accepted.
+	TokenVersion int `json:"tv"`
+	jwt.RegisteredClaims
+}
+
+// CreateAccessToken signs a short-lived access token carrying claims.
+func (s *KeySet) CreateAccessToken(claims AccessClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.Subject = claims.UserID
+
+	return s.Sign(&claims)
+}
+
+// ParseAccessToken verifies an access token and returns its claims.
+func (s *KeySet) ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	var claims AccessClaims
+	if err := s.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	// tokens with an audience are meant for something else, e.g. email links
+	if claims.UserID == "" || claims.SessionID == "" || len(claims.Audience) > 0 {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &claims, nil
+}
+
+// emailAudience marks tokens that prove control of an email address.
+const emailAudience = "email-verification"
+
+// EmailClaims are the claims of an email verification link. Email is the
+// address being confirmed, which may still be pending.
+type EmailClaims struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// CreateEmailToken signs a token confirming that userID controls email.
+func (s *KeySet) CreateEmailToken(userID string, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := EmailClaims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{emailAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return s.Sign(&claims)
+}
+
+// ParseEmailToken verifies an email verification token and returns its
+// claims.
+func (s *KeySet) ParseEmailToken(tokenString string) (*EmailClaims, error) {
+	var claims EmailClaims
+	if err := s.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" || claims.Email == "" || !slices.Contains(claims.Audience, emailAudience) {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &claims, nil
+}
+
+// magicLinkAudience marks tokens that log a user in from a mailed link.
+const magicLinkAudience = "magic-link"
+
+// MagicLinkClaims are the claims of a magic link. The token ID names the
+// stored link, which can be used once, and Email is the address it was sent
+// to.
+type MagicLinkClaims struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// CreateMagicLinkToken signs the magic link linkID for userID, mailed to
+// email.
+func (s *KeySet) CreateMagicLinkToken(linkID string, userID string, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := MagicLinkClaims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        linkID,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{magicLinkAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return s.Sign(&claims)
+}
+
+// ParseMagicLinkToken verifies a magic link token and returns its claims.
+func (s *KeySet) ParseMagicLinkToken(tokenString string) (*MagicLinkClaims, error) {
+	var claims MagicLinkClaims
+	if err := s.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	if claims.ID == "" || claims.UserID == "" || claims.Email == "" || !slices.Contains(claims.Audience, magicLinkAudience) {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &claims, nil
+}
+
+// mfaAudience marks tokens that only allow submitting a second factor.
+const mfaAudience = "mfa-challenge"
+
+// MFAClaims are the claims of the challenge token returned by a login that
+// still needs a second factor.
+type MFAClaims struct {
+	UserID string `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// CreateMFAToken signs a challenge token for userID, who passed the
+// password check.
+func (s *KeySet) CreateMFAToken(userID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := MFAClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return s.Sign(&claims)
+}
+
+// ParseMFAToken verifies a challenge token and returns its claims.
+func (s *KeySet) ParseMFAToken(tokenString string) (*MFAClaims, error) {
+	var claims MFAClaims
+	if err := s.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" || !slices.Contains(claims.Audience, mfaAudience) {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &claims, nil
+}
+
+// oidcStateAudience marks tokens holding the state of a login at an OpenID
+// Connect provider.
+const oidcStateAudience = "oidc-state"
+
+// OIDCStateClaims remember what a login redirected to a provider has to
+// check when it comes back. They are kept in a cookie of the browser.
+type OIDCStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	// Verifier is the PKCE code verifier.
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// CreateOIDCStateToken signs the state of a login started at a provider.
+func (s *KeySet) CreateOIDCStateToken(claims OIDCStateClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{oidcStateAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	return s.Sign(&claims)
+}
+
+// ParseOIDCStateToken verifies a state token and returns its claims.
+func (s *KeySet) ParseOIDCStateToken(tokenString string) (*OIDCStateClaims, error) {
+	var claims OIDCStateClaims
+	if err := s.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	if claims.State == "" || claims.Verifier == "" || !slices.Contains(claims.Audience, oidcStateAudience) {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return &claims, nil
+}
@@ -0,0 +1,214 @@
+// Package jwtauth signs and verifies GopherPost access tokens with a set of
+// keys identified by kid, so keys can be rotated without downtime.
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing or verification key. Private is nil for keys that are
+// only kept to verify tokens issued before a rotation.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key tokens are accepted from and the one new tokens
+// are signed with.
+type KeySet struct {
+	keys    map[string]*Key
+	signing *Key
+	// hmacSecret verifies tokens without a kid and signs new ones when no
+	// asymmetric key is configured.
+	hmacSecret []byte
+}
+
+// NewHMACKeySet signs and verifies HS256 tokens with a shared secret.
+func NewHMACKeySet(secret []byte) *KeySet {
+	return &KeySet{keys: map[string]*Key{}, hmacSecret: secret}
+}
+
+// LoadKeySet reads keys from PEM files listed in spec as comma-separated
+// kid=path pairs. Private keys (RSA or Ed25519) can sign, public keys only
+// verify. signingKID picks the signing key and may be empty when exactly one
+// private key is configured. A non-empty hmacSecret keeps HS256 tokens
+// without a kid valid, and is the only key when spec is empty.
+func LoadKeySet(spec string, signingKID string, hmacSecret string) (*KeySet, error) {
+	set := &KeySet{keys: map[string]*Key{}}
+	if hmacSecret != "" {
+		set.hmacSecret = []byte(hmacSecret)
+	}
+
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid key entry %q, want kid=path", entry)
+		}
+		if _, exists := set.keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", kid)
+		}
+
+		key, err := loadKeyFile(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		set.keys[kid] = key
+	}
+
+	if len(set.keys) == 0 {
+		if set.hmacSecret == nil {
+			return nil, errors.New("no JWT keys configured")
+		}
+		return set, nil
+	}
+
+	if signingKID == "" {
+		for _, key := range set.keys {
+			if key.Private == nil {
+				continue
+			}
+			if set.signing != nil {
+				return nil, errors.New("several private keys configured, the signing key id is required")
+			}
+			set.signing = key
+		}
+		if set.signing == nil {
+			return nil, errors.New("no private key configured to sign tokens")
+		}
+		return set, nil
+	}
+
+	key, ok := set.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q: %w", signingKID, ErrUnknownKey)
+	}
+	if key.Private == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKID)
+	}
+	set.signing = key
+
+	return set, nil
+}
+
+func loadKeyFile(kid string, path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key %q: %w", kid, err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM block in %s", kid, path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+
+	return newKey(kid, parsed)
+}
+
+func newKey(kid string, parsed any) (*Key, error) {
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, parsed)
+	}
+	return key, nil
+}
+
+// Sign signs claims with the current signing key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if s.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.hmacSecret)
+	}
+
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.Private)
+}
+
+// Parse verifies tokenString and decodes it into claims.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFor,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA", "HS256"}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrTokenInvalidClaims
+	}
+	return nil
+}
+
+// keyFor picks the verification key by kid. The algorithm must match the
+// key, so a public key can never be abused as an HMAC secret.
+func (s *KeySet) keyFor(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if s.hmacSecret == nil || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, ErrUnknownKey
+		}
+		return s.hmacSecret, nil
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("kid %q: %w", kid, ErrUnknownKey)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("kid %q does not sign %s tokens", kid, token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// PublicKeys returns every asymmetric key sorted by kid.
+func (s *KeySet) PublicKeys() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
@@ -0,0 +1,185 @@
+package jwtauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func newRSAKeyFile(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+}
+
+func newEd25519KeyFile(t *testing.T) (string, string) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	return writePEM(t, "ed.pem", "PRIVATE KEY", privateDER), writePEM(t, "ed.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	rsaPath := newRSAKeyFile(t)
+	edPath, edPublicPath := newEd25519KeyFile(t)
+
+	old, err := LoadKeySet("2025-01="+rsaPath, "", "")
+	require.NoError(t, err)
+	oldToken, err := old.CreateAccessToken(AccessClaims{UserID: "u1", SessionID: "s1", TokenVersion: 1}, time.Minute)
+	require.NoError(t, err)
+
+	// rotasi: kunci baru menandatangani, kunci lama masih memverifikasi
+	rotated, err := LoadKeySet("2025-01="+rsaPath+",2025-06="+edPath, "2025-06", "")
+	require.NoError(t, err)
+
+	claims, err := rotated.ParseAccessToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+
+	newToken, err := rotated.CreateAccessToken(AccessClaims{UserID: "u2", SessionID: "s2", TokenVersion: 3}, time.Minute)
+	require.NoError(t, err)
+	_, err = old.ParseAccessToken(newToken)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// layanan lain cukup punya kunci publik
+	_, err = LoadKeySet("2025-06="+edPublicPath, "2025-06", "")
+	require.Error(t, err, "a public key cannot sign")
+	verifier, err := LoadKeySet("2025-06="+edPublicPath+",2025-01="+rsaPath, "2025-01", "")
+	require.NoError(t, err)
+	claims, err = verifier.ParseAccessToken(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.TokenVersion)
+
+	jwks := rotated.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+	assert.Equal(t, "EdDSA", jwks.Keys[1].Alg)
+}
+
+func TestKeySetRejectsForgedTokens(t *testing.T) {
+	set, err := LoadKeySet("k1="+newRSAKeyFile(t), "", "hmac-secret")
+	require.NoError(t, err)
+
+	// HMAC yang ditandatangani dengan kunci publik sebagai secret tidak boleh lolos
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessClaims{
+		UserID: "u1", SessionID: "s1",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	forged.Header["kid"] = "k1"
+	forgedString, err := forged.SignedString([]byte("anything"))
+	require.NoError(t, err)
+	_, err = set.ParseAccessToken(forgedString)
+	assert.Error(t, err)
+
+	// token HMAC lama tanpa kid tetap diterima selama JWT_SECRET masih diset
+	legacy, err := NewHMACKeySet([]byte("hmac-secret")).CreateAccessToken(AccessClaims{UserID: "u1", SessionID: "s1"}, time.Minute)
+	require.NoError(t, err)
+	_, err = set.ParseAccessToken(legacy)
+	assert.NoError(t, err)
+
+	expired, err := set.CreateAccessToken(AccessClaims{UserID: "u1", SessionID: "s1"}, -time.Minute)
+	require.NoError(t, err)
+	_, err = set.ParseAccessToken(expired)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestLoadKeySetErrors(t *testing.T) {
+	_, err := LoadKeySet("", "", "")
+	assert.Error(t, err)
+
+	_, err = LoadKeySet("broken", "", "")
+	assert.Error(t, err)
+
+	_, err = LoadKeySet("a="+newRSAKeyFile(t)+",b="+newRSAKeyFile(t), "", "")
+	assert.Error(t, err, "several private keys need an explicit signing kid")
+
+	_, err = LoadKeySet("a="+newRSAKeyFile(t), "missing", "")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestEmailTokenIsNotAnAccessToken(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+
+	emailToken, err := keys.CreateEmailToken("user-1", "gopher@example.com", time.Hour)
+	require.NoError(t, err)
+	claims, err := keys.ParseEmailToken(emailToken)
+	require.NoError(t, err)
+	assert.Equal(t, "gopher@example.com", claims.Email)
+
+	// token verifikasi email tidak boleh dipakai sebagai access token, dan sebaliknya
+	_, err = keys.ParseAccessToken(emailToken)
+	assert.Error(t, err)
+
+	accessToken, err := keys.CreateAccessToken(AccessClaims{UserID: "user-1", SessionID: "session-1"}, time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ParseEmailToken(accessToken)
+	assert.Error(t, err)
+}
+
+func TestMFATokenOnlyAllowsSecondFactor(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+
+	mfaToken, err := keys.CreateMFAToken("user-1", time.Minute)
+	require.NoError(t, err)
+	claims, err := keys.ParseMFAToken(mfaToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+
+	// token challenge bukan access token
+	_, err = keys.ParseAccessToken(mfaToken)
+	assert.Error(t, err)
+
+	emailToken, err := keys.CreateEmailToken("user-1", "gopher@example.com", time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ParseMFAToken(emailToken)
+	assert.Error(t, err)
+}
+
+func TestMagicLinkTokenOnlyLogsIn(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+
+	linkToken, err := keys.CreateMagicLinkToken("link-1", "user-1", "gopher@example.com", time.Minute)
+	require.NoError(t, err)
+	claims, err := keys.ParseMagicLinkToken(linkToken)
+	require.NoError(t, err)
+	assert.Equal(t, "link-1", claims.ID)
+	assert.Equal(t, "gopher@example.com", claims.Email)
+
+	// magic link bukan access token maupun token verifikasi email
+	_, err = keys.ParseAccessToken(linkToken)
+	assert.Error(t, err)
+	_, err = keys.ParseEmailToken(linkToken)
+	assert.Error(t, err)
+
+	emailToken, err := keys.CreateEmailToken("user-1", "gopher@example.com", time.Hour)
+	require.NoError(t, err)
+	_, err = keys.ParseMagicLinkToken(emailToken)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+// Package lockout slows down and then blocks repeated failed logins. After
+// FreeFailures, every failure of a key waits exponentially longer before the
+// next attempt, and MaxFailures failures in a row lock the key for Lockout.
+package lockout
+
+import (
+	"context"
+	"gopher-post/db"
+	"time"
+)
+
+// Policy configures a Limiter. A zero BaseDelay disables the backoff and a
+// zero MaxFailures disables the lockout.
+type Policy struct {
+	// FreeFailures are allowed without any wait, for typos.
+	FreeFailures int
+	// BaseDelay is the wait after the first failure past FreeFailures,
+	// doubled by every further one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxFailures failures in a row lock the key for Lockout.
+	MaxFailures int
+	Lockout     time.Duration
+	// Window forgets failures when none happened for this long.
+	Window time.Duration
+}
+
+// Delay returns how long a key with failures consecutive failures waits.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	if p.BaseDelay <= 0 || failures <= p.FreeFailures {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Limiter applies a Policy to keys stored in an AttemptStore.
+type Limiter struct {
+	store  db.AttemptStore
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store db.AttemptStore, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// RetryAfter returns how long key still has to wait, zero when it may try
+// now.
+func (l *Limiter) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	attempt, err := l.store.GetLoginAttempt(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if attempt.LockedUntil == nil {
+		return 0, nil
+	}
+	return max(attempt.LockedUntil.Sub(l.now()), 0), nil
+}
+
+// Fail records a failed attempt of key and returns how long it has to wait
+// before the next one.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	attempt, err := l.store.RecordLoginFailure(ctx, key, l.policy.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	delay := l.policy.Delay(attempt.Failures)
+	if delay <= 0 {
+		return 0, nil
+	}
+	if err := l.store.LockLogin(ctx, key, l.now().Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset forgets the failures of key after a successful attempt.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.ResetLoginAttempts(ctx, key)
+}
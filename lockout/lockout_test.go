@@ -0,0 +1,77 @@
+package lockout
+
+import (
+	"gopher-post/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+		MaxFailures: 6,
+		Lockout:     15 * time.Minute,
+	}
+
+	assert.Equal(t, time.Duration(0), policy.Delay(0))
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 8*time.Second, policy.Delay(4))
+	// dibatasi MaxDelay sebelum lockout
+	assert.Equal(t, 10*time.Second, policy.Delay(5))
+	assert.Equal(t, 15*time.Minute, policy.Delay(6))
+	assert.Equal(t, 15*time.Minute, policy.Delay(100))
+
+	// tanpa backoff, hanya lockout
+	assert.Equal(t, time.Duration(0), Policy{MaxFailures: 3, Lockout: time.Minute}.Delay(2))
+	assert.Equal(t, time.Minute, Policy{MaxFailures: 3, Lockout: time.Minute}.Delay(3))
+
+	// dua kesalahan pertama gratis
+	free := Policy{FreeFailures: 2, BaseDelay: time.Second, MaxDelay: time.Minute}
+	assert.Equal(t, time.Duration(0), free.Delay(2))
+	assert.Equal(t, time.Second, free.Delay(3))
+	assert.Equal(t, 2*time.Second, free.Delay(4))
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := t.Context()
+	limiter := New(db.NewMemoryStore(), Policy{
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		MaxFailures: 3,
+		Lockout:     time.Hour,
+		Window:      time.Hour,
+	})
+
+	wait, err := limiter.RetryAfter(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = limiter.Fail(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	wait, err = limiter.RetryAfter(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Positive(t, wait)
+
+	_, err = limiter.Fail(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	wait, err = limiter.Fail(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, wait)
+
+	// key lain tidak terpengaruh
+	wait, err = limiter.RetryAfter(ctx, "account:other@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	require.NoError(t, limiter.Reset(ctx, "account:gopher@example.com"))
+	wait, err = limiter.RetryAfter(ctx, "account:gopher@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer is meant for local development. It logs every message and, when
+// Dir is set, also writes it to Dir as an .eml file.
+type LogMailer struct {
+	From string
+	Dir  string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := validHeader(m.From, msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	if m.Dir == "" {
+		slog.InfoContext(ctx, "Mail not sent, logged instead",
+			"to", msg.To,
+			"subject", msg.Subject,
+			"body", msg.Body,
+		)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("mailer: create mail dir: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405.000000000"), sanitize(msg.To))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg, now), 0o600); err != nil {
+		return fmt.Errorf("mailer: write %s: %w", path, err)
+	}
+
+	slog.InfoContext(ctx, "Mail written to file", "to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
+
+// sanitize keeps an address usable as part of a file name.
+func sanitize(address string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		default:
+			return '_'
+		}
+	}, address)
+}
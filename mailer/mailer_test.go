@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	raw := string(format("GopherPost <noreply@example.com>", Message{
+		To:      "gopher@example.com",
+		Subject: "Atur ulang password",
+		Body:    "Halo\nKlik link ini",
+	}, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	assert.Contains(t, raw, "From: GopherPost <noreply@example.com>\r\n")
+	assert.Contains(t, raw, "To: gopher@example.com\r\n")
+	assert.Contains(t, raw, "Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nHalo\r\nKlik link ini"))
+}
+
+func TestLogMailerWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	m := &LogMailer{From: "noreply@example.com", Dir: dir}
+
+	require.NoError(t, m.Send(t.Context(), Message{To: "gopher@example.com", Subject: "Tes", Body: "Isi"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, filepath.Base(files[0]), "gopher@example.com")
+
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Subject: Tes\r\n")
+}
+
+func TestRejectsHeaderInjection(t *testing.T) {
+	m := &LogMailer{From: "noreply@example.com"}
+	err := m.Send(t.Context(), Message{To: "a@example.com\r\nBcc: b@example.com", Subject: "Tes"})
+	assert.Error(t, err)
+}
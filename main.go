@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"gopher-post/config"
 	"gopher-post/db"
 	"gopher-post/handlers"
+	"gopher-post/jobs"
+	"gopher-post/jwtauth"
+	"gopher-post/lockout"
+	"gopher-post/mailer"
+	"gopher-post/middleware"
+	"gopher-post/oidc"
+	"gopher-post/passhash"
 	"gopher-post/routes"
+	"gopher-post/utils"
+	"gopher-post/workpool"
 	"log/slog"
 	"net/http"
 	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // @title 			GopherPost API
@@ -33,24 +50,207 @@ func main() {
 		slog.Info("File .env not found, using system environment variables")
 	}
 
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		slog.Error("DB_URL not found in .env file")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		os.Exit(runRole(os.Args[2:]))
+	}
+
+	utils.SetPasswordHasher(newPasswordHasher())
+
+	var store db.Store
+	if os.Getenv("STORAGE") == "memory" {
+		slog.Warn("Using in-memory storage, data will be lost on restart")
+		store = db.NewMemoryStore()
+	} else {
+		dbURL := os.Getenv("DB_URL")
+		if dbURL == "" {
+			slog.Error("DB_URL not found in .env file")
+			os.Exit(1)
+		}
+
+		timeouts := db.Timeouts{
+			Read:      config.Duration("DB_READ_TIMEOUT", 5*time.Second),
+			Write:     config.Duration("DB_WRITE_TIMEOUT", 5*time.Second),
+			Statement: config.Duration("DB_STATEMENT_TIMEOUT", 30*time.Second),
+		}
+
+		dbpool := db.InitDB(context.Background(), dbURL, timeouts)
+		defer dbpool.Close()
+
+		if os.Getenv("MIGRATE_ON_START") == "true" {
+			migrator, err := db.NewMigrator(dbpool)
+			if err != nil {
+				slog.Error("Failed load migrations", "error", err)
+				os.Exit(1)
+			}
+
+			applied, err := migrator.Up(context.Background())
+			if err != nil {
+				slog.Error("Migration on start failed", "error", err)
+				os.Exit(1)
+			}
+			slog.Info("Migrations applied", "applied", applied)
+		}
+
+		searchLanguage := config.String("SEARCH_LANGUAGE", "simple")
+		if !slices.Contains(db.SearchLanguages, searchLanguage) {
+			slog.Error("Invalid SEARCH_LANGUAGE", "value", searchLanguage, "allowed", db.SearchLanguages)
+			os.Exit(1)
+		}
+
+		store = db.NewPostgresStore(dbpool, timeouts, searchLanguage)
+	}
+
+	keys, err := jwtauth.LoadKeySet(os.Getenv("JWT_KEYS"), os.Getenv("JWT_SIGNING_KID"), os.Getenv("JWT_SECRET"))
+	if err != nil {
+		slog.Error("Failed load JWT keys", "error", err)
 		os.Exit(1)
 	}
 
-	dbpool := db.InitDB(dbURL)
-	defer dbpool.Close()
+	srv := handlers.NewServer(store, keys)
+	srv.RequireIfMatch = config.Bool("REQUIRE_IF_MATCH", false)
+	srv.AccessTokenTTL = config.Duration("ACCESS_TOKEN_TTL", srv.AccessTokenTTL)
+	srv.RefreshTokenTTL = config.Duration("REFRESH_TOKEN_TTL", srv.RefreshTokenTTL)
+	srv.MFATokenTTL = config.Duration("MFA_TOKEN_TTL", srv.MFATokenTTL)
+	srv.TOTPIssuer = config.String("TOTP_ISSUER", srv.TOTPIssuer)
+	srv.PersonalTokenMaxTTL = config.Duration("PERSONAL_TOKEN_MAX_TTL", srv.PersonalTokenMaxTTL)
+	srv.TokenVersions = middleware.NewTokenVersionCache(store, config.Duration("TOKEN_VERSION_CACHE_TTL", 5*time.Second))
+	srv.Mailer = newMailer()
+	srv.PublicURL = strings.TrimSuffix(config.String("PUBLIC_URL", srv.PublicURL), "/")
+	srv.PasswordResetTTL = config.Duration("PASSWORD_RESET_TTL", srv.PasswordResetTTL)
+	srv.EmailVerificationTTL = config.Duration("EMAIL_VERIFICATION_TTL", srv.EmailVerificationTTL)
+	srv.MagicLinkTTL = config.Duration("MAGIC_LINK_TTL", srv.MagicLinkTTL)
+	srv.RequireVerifiedEmail = config.Bool("REQUIRE_VERIFIED_EMAIL_TO_POST", false)
+	srv.OIDCProviders = newOIDCProviders(srv.PublicURL)
 
-	srv := &handlers.Server{
-		DB: dbpool,
+	srv.PasswordPool = workpool.New(config.Int("PASSWORD_WORKERS", runtime.NumCPU()), config.Int("PASSWORD_QUEUE", 4*runtime.NumCPU()))
+	expvar.Publish("password_pool", expvar.Func(func() any { return srv.PasswordPool.Stats() }))
+
+	accountLockout := handlers.DefaultAccountLockout
+	accountLockout.FreeFailures = config.Int("LOGIN_FREE_FAILURES", accountLockout.FreeFailures)
+	accountLockout.MaxFailures = config.Int("LOGIN_MAX_FAILURES", accountLockout.MaxFailures)
+	ipLockout := handlers.DefaultIPLockout
+	ipLockout.FreeFailures = config.Int("LOGIN_IP_FREE_FAILURES", ipLockout.FreeFailures)
+	ipLockout.MaxFailures = config.Int("LOGIN_IP_MAX_FAILURES", ipLockout.MaxFailures)
+	for _, policy := range []*lockout.Policy{&accountLockout, &ipLockout} {
+		policy.BaseDelay = config.Duration("LOGIN_BACKOFF_BASE", policy.BaseDelay)
+		policy.MaxDelay = config.Duration("LOGIN_BACKOFF_MAX", policy.MaxDelay)
+		policy.Lockout = config.Duration("LOGIN_LOCKOUT", policy.Lockout)
+		policy.Window = config.Duration("LOGIN_FAILURE_WINDOW", policy.Window)
+	}
+	srv.AccountLockout = lockout.New(store, accountLockout)
+	srv.IPLockout = lockout.New(store, ipLockout)
+	go jobs.RunLoginAttemptPurge(context.Background(), store, max(accountLockout.Window, ipLockout.Window), time.Hour)
+
+	if retention := config.Duration("TRASH_RETENTION", 30*24*time.Hour); retention > 0 {
+		go jobs.RunTrashPurge(context.Background(), store, retention, config.Duration("TRASH_PURGE_INTERVAL", time.Hour))
 	}
 
 	r := routes.SetupRoutes(srv)
 
+	// expvar serves /debug/vars on the default mux, kept off the public port
+	if addr := config.String("METRICS_ADDR", ""); addr != "" {
+		go func() {
+			slog.Info("Metrics server starting", "addr", addr)
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				slog.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              ":8080",
+		Handler:           middleware.TimeoutMiddleware(config.Duration("REQUEST_TIMEOUT", 15*time.Second))(r),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+
 	slog.Info("Server starting", "port", 8080)
-	if err := http.ListenAndServe(":8080", r); err != nil {
+	if err := server.ListenAndServe(); err != nil {
 		slog.Error("Server failed to start", "error", err)
 		os.Exit(1)
 	}
 }
+
+// newMailer picks the mail backend from MAILER: "smtp" for a real server,
+// anything else logs mails or writes them to MAIL_DIR.
+func newMailer() mailer.Mailer {
+	from := config.String("MAIL_FROM", "GopherPost <noreply@localhost>")
+	if os.Getenv("MAILER") == "smtp" {
+		return &mailer.SMTPMailer{
+			Addr:     config.String("SMTP_ADDR", "localhost:587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+
+	slog.Warn("Using log mailer, emails are not delivered", "dir", os.Getenv("MAIL_DIR"))
+	return &mailer.LogMailer{From: from, Dir: os.Getenv("MAIL_DIR")}
+}
+
+// newPasswordHasher picks the algorithm of new password hashes from
+// PASSWORD_HASH, "argon2id" or "bcrypt", with its cost. Hashes made with
+// other settings are upgraded on the next login.
+func newPasswordHasher() *passhash.Registry {
+	var hasher passhash.Hasher
+	switch algorithm := config.String("PASSWORD_HASH", "argon2id"); algorithm {
+	case "argon2id":
+		params := passhash.DefaultArgon2id
+		params.Memory = uint32(config.Int("ARGON2_MEMORY_KIB", int(params.Memory)))
+		params.Time = uint32(config.Int("ARGON2_TIME", int(params.Time)))
+		params.Threads = uint8(config.Int("ARGON2_THREADS", int(params.Threads)))
+		if params.Memory < 8*uint32(params.Threads) || params.Time < 1 || params.Threads < 1 {
+			slog.Error("Invalid argon2id parameters", "memory_kib", params.Memory, "time", params.Time, "threads", params.Threads)
+			os.Exit(1)
+		}
+		hasher = params
+	case "bcrypt":
+		cost := config.Int("BCRYPT_COST", passhash.DefaultBcrypt.Cost)
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			slog.Error("Invalid BCRYPT_COST", "cost", cost)
+			os.Exit(1)
+		}
+		hasher = passhash.Bcrypt{Cost: cost}
+	default:
+		slog.Error("Unknown PASSWORD_HASH", "algorithm", algorithm)
+		os.Exit(1)
+	}
+
+	return passhash.New(hasher)
+}
+
+// newOIDCProviders configures the providers listed in OIDC_PROVIDERS, e.g.
+// "google,gitlab". Each needs OIDC_<NAME>_ISSUER, _CLIENT_ID and
+// _CLIENT_SECRET; _SCOPES and _REDIRECT_URL are optional.
+func newOIDCProviders(publicURL string) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range strings.Split(config.String("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  config.String(prefix+"REDIRECT_URL", publicURL+"/auth/oidc/"+name+"/callback"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			slog.Error("OIDC provider needs an issuer and a client id", "provider", name)
+			os.Exit(1)
+		}
+
+		providers[name] = oidc.NewProvider(cfg, &http.Client{Timeout: 10 * time.Second})
+		slog.Info("OIDC provider configured", "provider", name, "issuer", cfg.Issuer)
+	}
+	return providers
+}
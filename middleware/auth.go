@@ -2,48 +2,143 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"gopher-post/db"
+	"gopher-post/jwtauth"
+	"gopher-post/models"
+	"gopher-post/policy"
+	"gopher-post/utils"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey    contextKey = "userID"
+	SessionIDKey contextKey = "sessionID"
+	RoleKey      contextKey = "role"
+	// ScopesKey holds the scopes of a personal access token. Requests
+	// authenticated with a login carry none and are not limited by scope.
+	ScopesKey contextKey = "scopes"
+)
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		secret := os.Getenv("JWT_SECRET")
-		var jwtKey = []byte(secret)
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-			return
-		}
+// SessionChecker reports whether the login session a token was issued for
+// is still live. It returns db.ErrNotFound once the session is revoked.
+type SessionChecker interface {
+	TouchSession(ctx context.Context, id string, userID string) error
+}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+// PersonalTokenChecker looks up live personal access tokens by hash. It
+// returns db.ErrNotFound for unknown, expired and revoked tokens.
+type PersonalTokenChecker interface {
+	AuthenticatePersonalToken(ctx context.Context, tokenHash string) (*models.PersonalToken, error)
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
+// NewAuthMiddleware verifies the bearer token and rejects it when its user
+// has been deleted or disabled, its token version is outdated or its session
+// has been revoked, so these take effect before the access token expires.
+// Personal access tokens are accepted as well, but only on routes guarded by
+// RequireScope.
+func NewAuthMiddleware(keys *jwtauth.KeySet, sessions SessionChecker, tokens PersonalTokenChecker, versions *TokenVersionCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+				return
+			}
+
+			tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+			if strings.HasPrefix(tokenString, models.PersonalTokenPrefix) {
+				authenticatePersonalToken(w, r, next, tokens, versions, tokenString)
+				return
+			}
+
+			claims, err := keys.ParseAccessToken(tokenString)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			userID, sessionID := claims.UserID, claims.SessionID
+
+			currentVersion, err := versions.Version(r.Context(), userID)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				slog.ErrorContext(r.Context(), "Failed to check token version", "user_id", userID, "error", err)
+				http.Error(w, "Failed to check token", http.StatusInternalServerError)
+				return
+			}
+			if err != nil || claims.TokenVersion != currentVersion {
+				slog.WarnContext(r.Context(), "Token of deleted, disabled or re-keyed user rejected",
+					"user_id", userID,
+					"token_version", claims.TokenVersion,
+				)
+				http.Error(w, "Token revoked", http.StatusUnauthorized)
+				return
 			}
-			return jwtKey, nil
-		})
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+			err = sessions.TouchSession(r.Context(), sessionID, userID)
+			if errors.Is(err, db.ErrNotFound) {
+				slog.WarnContext(r.Context(), "Token of revoked session rejected",
+					"session_id", sessionID,
+					"user_id", userID,
+				)
+				http.Error(w, "Session revoked", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check session", "session_id", sessionID, "error", err)
+				http.Error(w, "Failed to check session", http.StatusInternalServerError)
+				return
+			}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userID := claims["user_id"].(string)
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
+			ctx = context.WithValue(ctx, SessionIDKey, sessionID)
+			ctx = context.WithValue(ctx, RoleKey, claims.Role)
+			ctx = policy.WithSubject(ctx, policy.Subject{UserID: userID, Role: claims.Role})
 			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-		}
-	})
+		})
+	}
+}
+
+// authenticatePersonalToken is the part of the auth middleware handling
+// personal access tokens.
+func authenticatePersonalToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokens PersonalTokenChecker, versions *TokenVersionCache, tokenString string) {
+	token, err := tokens.AuthenticatePersonalToken(r.Context(), utils.HashToken(tokenString))
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Unknown, expired or revoked personal token rejected")
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to check personal token", "error", err)
+		http.Error(w, "Failed to check token", http.StatusInternalServerError)
+		return
+	}
+
+	// deleted and disabled users lose their tokens too
+	_, err = versions.Version(r.Context(), token.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Personal token of deleted or disabled user rejected", "user_id", token.UserID, "token_id", token.ID)
+		http.Error(w, "Token revoked", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to check token version", "user_id", token.UserID, "error", err)
+		http.Error(w, "Failed to check token", http.StatusInternalServerError)
+		return
+	}
+
+	if !scopedRoute(r) {
+		slog.WarnContext(r.Context(), "Personal token used on a login-only route", "user_id", token.UserID, "token_id", token.ID)
+		http.Error(w, "Personal access tokens cannot be used here", http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), UserIDKey, token.UserID)
+	ctx = context.WithValue(ctx, RoleKey, token.UserRole)
+	ctx = context.WithValue(ctx, ScopesKey, token.Scopes)
+	ctx = policy.WithSubject(ctx, policy.Subject{UserID: token.UserID, Role: token.UserRole})
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CacheMiddleware makes successful GET responses cacheable. It sets
+// Cache-Control to cacheControl (left untouched when empty), adds a weak ETag
+// computed from the body unless the handler already set one, and answers
+// 304 Not Modified when the request's If-None-Match shows the client already
+// has this representation. If-Modified-Since is not honored: Last-Modified
+// has one second precision, so a second edit within the same second would
+// look unchanged. Responses vary by Authorization, since what a caller sees
+// may depend on who they are.
+//
+// The whole response is buffered, so it must only wrap handlers with bounded
+// payloads such as paginated listings.
+func CacheMiddleware(cacheControl string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			header := w.Header()
+			for key, values := range rec.header {
+				header[key] = values
+			}
+			header.Add("Vary", "Authorization")
+
+			if rec.status != http.StatusOK {
+				w.WriteHeader(rec.status)
+				w.Write(rec.body.Bytes())
+				return
+			}
+
+			if cacheControl != "" {
+				header.Set("Cache-Control", cacheControl)
+			}
+			etag := header.Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(rec.body.Bytes())
+				etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+				header.Set("ETag", etag)
+			}
+
+			if notModified(r, etag) {
+				// a 304 keeps the validators but carries no body
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
+
+// notModified evaluates If-None-Match with the weak comparison of RFC 9110
+// section 13.1.2.
+func notModified(r *http.Request, etag string) bool {
+	for tag := range strings.SplitSeq(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || weakETag(tag) == weakETag(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// weakETag strips the weak marker, If-None-Match uses the weak comparison.
+func weakETag(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
+}
+
+// bufferedResponse holds a handler's response until the middleware has
+// decided whether to send it.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+// HasRole reports whether the authenticated user has one of roles.
+func HasRole(ctx context.Context, roles ...string) bool {
+	role, _ := ctx.Value(RoleKey).(string)
+	return role != "" && slices.Contains(roles, role)
+}
+
+// RequireRole only lets requests of users with one of roles through. It must
+// run after the auth middleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasRole(r.Context(), roles...) {
+				slog.WarnContext(r.Context(), "Forbidden: missing role",
+					"user_id", r.Context().Value(UserIDKey),
+					"role", r.Context().Value(RoleKey),
+					"required", roles,
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOrRole only lets requests through whose user is the one named by
+// the route variable param, or who has one of roles. It must run after the
+// auth middleware.
+func RequireSelfOrRole(param string, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDKey).(string)
+			if (userID == "" || userID != mux.Vars(r)[param]) && !HasRole(r.Context(), roles...) {
+				slog.WarnContext(r.Context(), "Forbidden: neither the user nor an allowed role",
+					"user_id", userID,
+					"role", r.Context().Value(RoleKey),
+					"required", roles,
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
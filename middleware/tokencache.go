@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"gopher-post/db"
+	"sync"
+	"time"
+)
+
+// TokenVersionSource looks up the token version of a user. Deleted and
+// disabled users are reported as db.ErrNotFound.
+type TokenVersionSource interface {
+	GetTokenVersion(ctx context.Context, id string) (int, error)
+}
+
+// maxCachedVersions bounds the cache, expired entries are swept once it is
+// reached.
+const maxCachedVersions = 10_000
+
+// TokenVersionCache keeps token versions for a short TTL so checking them
+// does not cost a query per request. Changes made through this process are
+// seen immediately via Invalidate, changes made by other instances within
+// the TTL.
+type TokenVersionCache struct {
+	source TokenVersionSource
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]tokenVersionEntry
+	// generation counts invalidations and invalidated holds the generation
+	// of each user's latest one. A lookup that started before it may have
+	// read the old version and is not cached. forgotten is the generation
+	// at which invalidated was last cleared.
+	generation  uint64
+	invalidated map[string]uint64
+	forgotten   uint64
+}
+
+type tokenVersionEntry struct {
+	version int
+	// found is false for deleted or disabled users.
+	found   bool
+	expires time.Time
+}
+
+func NewTokenVersionCache(source TokenVersionSource, ttl time.Duration) *TokenVersionCache {
+	return &TokenVersionCache{
+		source:      source,
+		ttl:         ttl,
+		now:         time.Now,
+		entries:     make(map[string]tokenVersionEntry),
+		invalidated: make(map[string]uint64),
+	}
+}
+
+// Version returns the current token version of userID, or db.ErrNotFound
+// when the user may not authenticate at all.
+func (c *TokenVersionCache) Version(ctx context.Context, userID string) (int, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	started := c.generation
+	c.mu.Unlock()
+
+	if !ok || !c.now().Before(entry.expires) {
+		version, err := c.source.GetTokenVersion(ctx, userID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			// transient failures are not cached
+			return 0, err
+		}
+
+		entry = tokenVersionEntry{version: version, found: err == nil, expires: c.now().Add(c.ttl)}
+		c.store(userID, entry, started)
+	}
+
+	if !entry.found {
+		return 0, db.ErrNotFound
+	}
+	return entry.version, nil
+}
+
+// Invalidate drops the cached version of userID, so the next request sees
+// a password change or a disabled account right away.
+func (c *TokenVersionCache) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+	c.generation++
+	c.invalidated[userID] = c.generation
+	if len(c.invalidated) >= maxCachedVersions {
+		clear(c.invalidated)
+		c.forgotten = c.generation
+	}
+}
+
+// store caches entry unless userID was invalidated after the lookup that
+// read it started.
+func (c *TokenVersionCache) store(userID string, entry tokenVersionEntry, started uint64) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.invalidated[userID] > started || c.forgotten > started {
+		return
+	}
+
+	if len(c.entries) >= maxCachedVersions {
+		now := c.now()
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxCachedVersions {
+			clear(c.entries)
+		}
+	}
+	c.entries[userID] = entry
+}
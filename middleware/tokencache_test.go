@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"gopher-post/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVersions struct {
+	versions map[string]int
+	err      error
+	calls    int
+	// during runs after the version has been read.
+	during func()
+}
+
+func (f *fakeVersions) GetTokenVersion(ctx context.Context, id string) (int, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	version, ok := f.versions[id]
+	if f.during != nil {
+		f.during()
+	}
+	if !ok {
+		return 0, db.ErrNotFound
+	}
+	return version, nil
+}
+
+func TestTokenVersionCache(t *testing.T) {
+	source := &fakeVersions{versions: map[string]int{"gopher": 1}}
+	cache := NewTokenVersionCache(source, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	version, err := cache.Version(t.Context(), "gopher")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	// masih dalam TTL: tidak query ulang
+	source.versions["gopher"] = 2
+	version, _ = cache.Version(t.Context(), "gopher")
+	assert.Equal(t, 1, version)
+	assert.Equal(t, 1, source.calls)
+
+	cache.Invalidate("gopher")
+	version, _ = cache.Version(t.Context(), "gopher")
+	assert.Equal(t, 2, version)
+
+	source.versions["gopher"] = 3
+	now = now.Add(2 * time.Minute)
+	version, _ = cache.Version(t.Context(), "gopher")
+	assert.Equal(t, 3, version)
+
+	_, err = cache.Version(t.Context(), "deleted")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func TestTokenVersionCacheSkipsTransientErrors(t *testing.T) {
+	source := &fakeVersions{err: errors.New("connection reset")}
+	cache := NewTokenVersionCache(source, time.Minute)
+
+	_, err := cache.Version(t.Context(), "gopher")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, db.ErrNotFound)
+
+	source.err = nil
+	source.versions = map[string]int{"gopher": 1}
+	version, err := cache.Version(t.Context(), "gopher")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+}
+
+func TestTokenVersionCacheInvalidateDuringLookup(t *testing.T) {
+	source := &fakeVersions{versions: map[string]int{"gopher": 1}}
+	cache := NewTokenVersionCache(source, time.Minute)
+
+	// versi lama sudah terbaca saat akun dinonaktifkan dan cache di-invalidate
+	source.during = func() {
+		source.during = nil
+		delete(source.versions, "gopher")
+		cache.Invalidate("gopher")
+	}
+	version, err := cache.Version(t.Context(), "gopher")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	// hasil yang basi tidak boleh tersimpan
+	_, err = cache.Version(t.Context(), "gopher")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.Equal(t, 2, source.calls)
+
+	// lookup berikutnya disimpan seperti biasa
+	_, err = cache.Version(t.Context(), "gopher")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.Equal(t, 2, source.calls)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher-post/db"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = "usage: gopher-post migrate up|down [steps]|status|create <name>"
+
+// runMigrate handles the "migrate" subcommand and returns the process exit
+// code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+
+		dir := os.Getenv("MIGRATIONS_DIR")
+		if dir == "" {
+			dir = "db/migrations"
+		}
+
+		upPath, downPath, err := db.CreateMigration(dir, args[1])
+		if err != nil {
+			slog.Error("Failed create migration", "error", err)
+			return 1
+		}
+
+		fmt.Println("created", upPath)
+		fmt.Println("created", downPath)
+		return 0
+	}
+
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		slog.Error("DB_URL not found in .env file")
+		return 1
+	}
+
+	ctx := context.Background()
+	dbpool := db.InitDB(ctx, dbURL, db.Timeouts{})
+	defer dbpool.Close()
+
+	migrator, err := db.NewMigrator(dbpool)
+	if err != nil {
+		slog.Error("Failed load migrations", "error", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			slog.Error("Migration up failed", "error", err)
+			return 1
+		}
+		slog.Info("Migration up finished", "applied", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			slog.Error("Migration down failed", "error", err)
+			return 1
+		}
+		slog.Info("Migration down finished", "rolled_back", rolledBack)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			slog.Error("Migration status failed", "error", err)
+			return 1
+		}
+
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%06d  %-40s  %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
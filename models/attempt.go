@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the consecutive failed logins of an account or IP.
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil is set while further attempts are refused.
+	LockedUntil *time.Time
+}
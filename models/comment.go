@@ -3,9 +3,10 @@ package models
 import "time"
 
 type Comment struct {
-	ID        string    `json:"id"`
-	Content   string    `json:"content"`
-	UserID    string    `json:"user_id"`
-	PostID    string    `json:"post_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string     `json:"id"`
+	Content   string     `json:"content"`
+	UserID    string     `json:"user_id"`
+	PostID    string     `json:"post_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"-"`
 }
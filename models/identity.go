@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Identity links an account at an OpenID Connect provider to a user.
+type Identity struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
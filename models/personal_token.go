@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// PersonalTokenPrefix starts every personal access token, so they are easy
+// to tell apart from JWTs and to find in leaked code.
+const PersonalTokenPrefix = "gpp_"
+
+// Scopes a personal access token can be granted.
+const (
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsWrite = "comments:write"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopePostsWrite, ScopeCommentsWrite}
+
+// PersonalToken is a named, expiring credential for automation. Requests
+// made with it act as its user, limited to its scopes.
+type PersonalToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// UserRole is the current role of the user, set when authenticating.
+	UserRole string `json:"-"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PostRevision is an immutable snapshot of a post taken on every change.
+type PostRevision struct {
+	PostID   string `json:"post_id"`
+	Revision int    `json:"revision"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	// EditorID is empty when the editor's account has been deleted.
+	EditorID  string    `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
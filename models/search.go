@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type SearchResult struct {
+	// Type is either "post" or "comment".
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	PostID string `json:"post_id"`
+	// Title is the title of the post the hit belongs to.
+	Title string `json:"title"`
+	// Snippet is an HTML-escaped excerpt of the matched content with terms
+	// wrapped in <mark>, safe to render as HTML.
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
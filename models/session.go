@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is one login of a user, shown in the list of active devices.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session of the request that listed the sessions.
+	Current bool `json:"current"`
+}
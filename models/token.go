@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RefreshToken is one link in a chain of rotated refresh tokens. All tokens
+// issued from the same login share a FamilyID, which is the session id.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// UsedAt is set once the token has been exchanged for a new one.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// PasswordReset is a single-use token mailed to a user who forgot their
+// password.
+type PasswordReset struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// MagicLink is a single-use login link mailed to a user. The link itself is
+// a signed token carrying the ID.
+type MagicLink struct {
+	ID        string
+	UserID    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TOTP is the authenticator of a user. Two-factor authentication is only
+// enabled once ConfirmedAt is set.
+type TOTP struct {
+	UserID      string
+	Secret      string
+	ConfirmedAt *time.Time
+	// LastCounter is the time step of the last accepted code.
+	LastCounter int64
+	CreatedAt   time.Time
+}
+
+// Enabled reports whether the authenticator has been confirmed.
+func (t *TOTP) Enabled() bool {
+	return t != nil && t.ConfirmedAt != nil
+}
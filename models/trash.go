@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TrashItem is a soft-deleted post or comment waiting to be restored or
+// purged.
+type TrashItem struct {
+	// Type is either "post" or "comment".
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	PostID  string `json:"post_id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// DeletedAt plus the retention period is when the item gets purged.
+	DeletedAt time.Time `json:"deleted_at"`
+}
@@ -1,11 +1,48 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
+
+// Roles a user can have. Moderators may remove any post or comment, admins
+// additionally manage users.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
 
 type User struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	Version      int    `json:"version"`
+	TokenVersion int    `json:"-"`
+	// EmailVerifiedAt is nil until the user confirms their email.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// PendingEmail replaces Email once it has been confirmed. Only the
+	// user and admins see it, see Public.
+	PendingEmail string `json:"pending_email,omitempty"`
+	// DisabledAt is set while the account is disabled. Only the user and
+	// admins see it, see Public.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// MagicLinkEnabled allows logging in with a link mailed to Email.
+	MagicLinkEnabled bool      `json:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Public returns u without the details only the user and admins may see.
+func (u User) Public() User {
+	u.PendingEmail = ""
+	u.DisabledAt = nil
+	return u
 }
@@ -0,0 +1,87 @@
+package oidc_test
+
+import (
+	"net/url"
+	"testing"
+
+	"gopher-post/oidc"
+	"gopher-post/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var gopher = oidctest.Identity{Subject: "42", Email: "gopher@example.com", EmailVerified: true, Name: "Gopher"}
+
+// login menjalankan flow lengkap dan mengembalikan code dari callback
+func login(t *testing.T, idp *oidctest.Provider, provider *oidc.Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(t.Context(), state, nonce, verifier)
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL, gopher)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(callback)
+	require.NoError(t, err)
+	assert.Equal(t, state, parsed.Query().Get("state"))
+	return parsed.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.NewProvider("gopher-post", "rahasia")
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config("stub", "http://localhost/callback"), nil)
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+	code := login(t, idp, provider, "state-1", "nonce-1", verifier)
+
+	claims, err := provider.Exchange(t.Context(), code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, "gopher@example.com", claims.Email)
+	assert.True(t, bool(claims.EmailVerified))
+
+	// code sekali pakai
+	_, err = provider.Exchange(t.Context(), code, verifier, "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsMismatches(t *testing.T) {
+	idp := oidctest.NewProvider("gopher-post", "rahasia")
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config("stub", "http://localhost/callback"), nil)
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+	other, err := oidc.NewVerifier()
+	require.NoError(t, err)
+
+	// verifier PKCE yang salah ditolak oleh provider
+	code := login(t, idp, provider, "state", "nonce", verifier)
+	_, err = provider.Exchange(t.Context(), code, other, "nonce")
+	assert.Error(t, err)
+
+	// nonce dari login lain
+	code = login(t, idp, provider, "state", "nonce", verifier)
+	_, err = provider.Exchange(t.Context(), code, verifier, "nonce-lain")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+
+	// client secret yang salah
+	wrongSecret := idp.Config("stub", "http://localhost/callback")
+	wrongSecret.ClientSecret = "salah"
+	code = login(t, idp, provider, "state", "nonce", verifier)
+	_, err = oidc.NewProvider(wrongSecret, nil).Exchange(t.Context(), code, verifier, "nonce")
+	assert.Error(t, err)
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	idp := oidctest.NewProvider("gopher-post", "rahasia")
+	defer idp.Close()
+
+	config := idp.Config("stub", "http://localhost/callback")
+	config.Issuer += "/"
+	_, err := oidc.NewProvider(config, nil).AuthCodeURL(t.Context(), "state", "nonce", "verifier")
+	assert.Error(t, err)
+}
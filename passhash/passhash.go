@@ -0,0 +1,74 @@
+// Package passhash hashes passwords with a configurable algorithm and
+// verifies hashes of every algorithm it knows. Each hash carries its
+// algorithm and parameters, so the configuration can change at any time:
+// old hashes keep working and are reported as needing a rehash.
+package passhash
+
+import "errors"
+
+// ErrUnknownHash is returned for hashes no registered Hasher recognises.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher is one password hashing algorithm with fixed parameters.
+type Hasher interface {
+	// Hash returns an encoded hash of password with a random salt.
+	Hash(password string) (string, error)
+	// Recognizes reports whether hash was produced by this algorithm, with
+	// any parameters.
+	Recognizes(hash string) bool
+	// Verify reports whether password matches hash.
+	Verify(password string, hash string) (bool, error)
+	// Outdated reports whether hash uses other parameters than the Hasher.
+	Outdated(hash string) bool
+}
+
+// Registry hashes with Default and verifies with whichever Hasher
+// recognises a hash.
+type Registry struct {
+	Default Hasher
+	// Others are only used to verify existing hashes.
+	Others []Hasher
+}
+
+// New returns a Registry hashing with def and verifying argon2id and bcrypt
+// hashes of any parameters.
+func New(def Hasher) *Registry {
+	return &Registry{Default: def, Others: []Hasher{DefaultArgon2id, DefaultBcrypt}}
+}
+
+func (r *Registry) Hash(password string) (string, error) {
+	return r.Default.Hash(password)
+}
+
+// Verify reports whether password matches hash. rehash is true when the
+// password matched but hash should be replaced by a new Hash of it.
+func (r *Registry) Verify(password string, hash string) (ok bool, rehash bool, err error) {
+	hasher := r.find(hash)
+	if hasher == nil {
+		return false, false, ErrUnknownHash
+	}
+
+	ok, err = hasher.Verify(password, hash)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	return true, r.NeedsRehash(hash), nil
+}
+
+// NeedsRehash reports whether hash was not produced by Default with its
+// current parameters.
+func (r *Registry) NeedsRehash(hash string) bool {
+	return !r.Default.Recognizes(hash) || r.Default.Outdated(hash)
+}
+
+func (r *Registry) find(hash string) Hasher {
+	if r.Default.Recognizes(hash) {
+		return r.Default
+	}
+	for _, hasher := range r.Others {
+		if hasher.Recognizes(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
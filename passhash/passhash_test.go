@@ -0,0 +1,84 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parameter murah supaya test cepat
+var (
+	testArgon2id = Argon2id{Memory: 64, Time: 1, Threads: 1}
+	testBcrypt   = Bcrypt{Cost: 4}
+)
+
+func TestHashers(t *testing.T) {
+	for _, hasher := range []Hasher{testArgon2id, testBcrypt} {
+		hash, err := hasher.Hash("secret123")
+		require.NoError(t, err)
+		assert.True(t, hasher.Recognizes(hash), hash)
+		assert.False(t, hasher.Outdated(hash), hash)
+
+		ok, err := hasher.Verify("secret123", hash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = hasher.Verify("salah", hash)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// salt acak, hash yang sama tidak boleh berulang
+		other, err := hasher.Hash("secret123")
+		require.NoError(t, err)
+		assert.NotEqual(t, hash, other)
+	}
+}
+
+func TestArgon2idEncoding(t *testing.T) {
+	hash, err := testArgon2id.Hash("secret123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	stronger := Argon2id{Memory: 128, Time: 1, Threads: 1}
+	assert.True(t, stronger.Outdated(hash))
+	// hash lama tetap diverifikasi dengan parameternya sendiri
+	ok, err := stronger.Verify("secret123", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = testArgon2id.Verify("secret123", "$argon2id$v=19$m=64,t=1,p=1$rusak")
+	assert.ErrorIs(t, err, ErrUnknownHash)
+}
+
+func TestRegistryRehash(t *testing.T) {
+	registry := &Registry{Default: testArgon2id, Others: []Hasher{testBcrypt}}
+
+	bcryptHash, err := testBcrypt.Hash("secret123")
+	require.NoError(t, err)
+	ok, rehash, err := registry.Verify("secret123", bcryptHash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash, "bcrypt hash should move to argon2id")
+
+	// password salah tidak pernah minta rehash
+	ok, rehash, err = registry.Verify("salah", bcryptHash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+
+	argonHash, err := registry.Hash("secret123")
+	require.NoError(t, err)
+	ok, rehash, err = registry.Verify("secret123", argonHash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	// cost bcrypt yang dinaikkan juga dianggap usang
+	registry = &Registry{Default: Bcrypt{Cost: 5}}
+	assert.True(t, registry.NeedsRehash(bcryptHash))
+
+	_, _, err = registry.Verify("secret123", "plaintext")
+	assert.ErrorIs(t, err, ErrUnknownHash)
+}
@@ -0,0 +1,116 @@
+// Package policy decides who may do what with posts, comments and users.
+// Rules are declared once in Default instead of being repeated in every
+// handler, so they can be tested without HTTP.
+package policy
+
+import (
+	"context"
+	"errors"
+	"gopher-post/models"
+	"slices"
+)
+
+var (
+	// ErrUnauthenticated is returned when the context carries no subject.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned when no rule of the action allows the subject.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Action names an operation guarded by a policy.
+type Action string
+
+const (
+	PostUpdate    Action = "post:update"
+	PostDelete    Action = "post:delete"
+	PostRollback  Action = "post:rollback"
+	CommentDelete Action = "comment:delete"
+	UserUpdate    Action = "user:update"
+	UserDelete    Action = "user:delete"
+	// UserViewPrivate reveals the account details in models.User that
+	// other users must not see.
+	UserViewPrivate Action = "user:view-private"
+)
+
+// Subject is the authenticated user performing an action.
+type Subject struct {
+	UserID string
+	Role   string
+}
+
+// Resource describes the target of an action. OwnerID is the author of a
+// post or comment, or the user itself; PostOwnerID is the author of the post
+// a comment belongs to.
+type Resource struct {
+	OwnerID     string
+	PostOwnerID string
+}
+
+// Rule reports whether subject may act on resource.
+type Rule func(subject Subject, resource Resource) bool
+
+// Owner allows the author of the resource.
+func Owner(subject Subject, resource Resource) bool {
+	return resource.OwnerID != "" && subject.UserID == resource.OwnerID
+}
+
+// PostOwner allows the author of the post a comment belongs to.
+func PostOwner(subject Subject, resource Resource) bool {
+	return resource.PostOwnerID != "" && subject.UserID == resource.PostOwnerID
+}
+
+// Role allows subjects with one of roles.
+func Role(roles ...string) Rule {
+	return func(subject Subject, _ Resource) bool {
+		return subject.Role != "" && slices.Contains(roles, subject.Role)
+	}
+}
+
+// Policy maps every action to the rules that allow it. An action is allowed
+// when any of its rules matches; unknown actions are denied.
+type Policy map[Action][]Rule
+
+// Default is the policy enforced by the API.
+var Default = Policy{
+	PostUpdate:    {Owner},
+	PostDelete:    {Owner, Role(models.RoleModerator, models.RoleAdmin)},
+	PostRollback:  {Owner},
+	CommentDelete: {Owner, PostOwner, Role(models.RoleModerator, models.RoleAdmin)},
+	UserUpdate:    {Owner, Role(models.RoleAdmin)},
+	UserDelete:    {Owner, Role(models.RoleAdmin)},
+
+	UserViewPrivate: {Owner, Role(models.RoleAdmin)},
+}
+
+// Allow checks subject against the rules of action.
+func (p Policy) Allow(subject Subject, action Action, resource Resource) error {
+	if subject.UserID == "" {
+		return ErrUnauthenticated
+	}
+
+	for _, rule := range p[action] {
+		if rule(subject, resource) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx carrying subject.
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFrom returns the subject stored in ctx, if any.
+func SubjectFrom(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(Subject)
+	return subject, ok
+}
+
+// Authorize checks the subject of ctx against the Default policy.
+func Authorize(ctx context.Context, action Action, resource Resource) error {
+	subject, _ := SubjectFrom(ctx)
+	return Default.Allow(subject, action, resource)
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"gopher-post/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	author := Subject{UserID: "author", Role: models.RoleUser}
+	postAuthor := Subject{UserID: "post-author", Role: models.RoleUser}
+	stranger := Subject{UserID: "stranger", Role: models.RoleUser}
+	moderator := Subject{UserID: "moderator", Role: models.RoleModerator}
+	admin := Subject{UserID: "admin", Role: models.RoleAdmin}
+
+	post := Resource{OwnerID: "author"}
+	comment := Resource{OwnerID: "author", PostOwnerID: "post-author"}
+	user := Resource{OwnerID: "author"}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource Resource
+		want     error
+	}{
+		{"author updates post", author, PostUpdate, post, nil},
+		{"moderator cannot update post", moderator, PostUpdate, post, ErrForbidden},
+		{"admin cannot update post", admin, PostUpdate, post, ErrForbidden},
+		{"stranger cannot delete post", stranger, PostDelete, post, ErrForbidden},
+		{"moderator deletes post", moderator, PostDelete, post, nil},
+		{"moderator cannot roll back post", moderator, PostRollback, post, ErrForbidden},
+		{"author deletes comment", author, CommentDelete, comment, nil},
+		{"post author deletes comment", postAuthor, CommentDelete, comment, nil},
+		{"stranger cannot delete comment", stranger, CommentDelete, comment, ErrForbidden},
+		{"moderator deletes comment", moderator, CommentDelete, comment, nil},
+		{"user updates self", author, UserUpdate, user, nil},
+		{"moderator cannot update user", moderator, UserUpdate, user, ErrForbidden},
+		{"admin deletes user", admin, UserDelete, user, nil},
+		{"user sees own private details", author, UserViewPrivate, user, nil},
+		{"stranger cannot see private details", stranger, UserViewPrivate, user, ErrForbidden},
+		{"moderator cannot see private details", moderator, UserViewPrivate, user, ErrForbidden},
+		{"admin sees private details", admin, UserViewPrivate, user, nil},
+		{"anonymous", Subject{}, PostDelete, post, ErrUnauthenticated},
+		{"unknown action", admin, Action("post:publish"), post, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, Default.Allow(tt.subject, tt.action, tt.resource), tt.want)
+		})
+	}
+}
+
+func TestOwnerRequiresOwnerID(t *testing.T) {
+	// resource tanpa pemilik tidak boleh cocok dengan subject kosong
+	assert.False(t, Owner(Subject{}, Resource{}))
+	assert.False(t, PostOwner(Subject{}, Resource{}))
+}
+
+func TestAuthorizeReadsSubjectFromContext(t *testing.T) {
+	ctx := t.Context()
+	assert.ErrorIs(t, Authorize(ctx, PostUpdate, Resource{OwnerID: "author"}), ErrUnauthenticated)
+
+	ctx = WithSubject(ctx, Subject{UserID: "author", Role: models.RoleUser})
+	assert.NoError(t, Authorize(ctx, PostUpdate, Resource{OwnerID: "author"}))
+	assert.ErrorIs(t, Authorize(ctx, PostUpdate, Resource{OwnerID: "other"}), ErrForbidden)
+}
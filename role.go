@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher-post/db"
+	"gopher-post/models"
+	"log/slog"
+	"os"
+)
+
+const roleUsage = "usage: gopher-post role <email> user|moderator|admin"
+
+// runRole handles the "role" subcommand, used to appoint the first admin,
+// and returns the process exit code. Like the admin API it only grants
+// moderator and admin to users with two-factor authentication enabled.
+func runRole(args []string) int {
+	if len(args) != 2 || !models.ValidRole(args[1]) {
+		fmt.Fprintln(os.Stderr, roleUsage)
+		return 2
+	}
+
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		slog.Error("DB_URL not found in .env file")
+		return 1
+	}
+
+	ctx := context.Background()
+	dbpool := db.InitDB(ctx, dbURL, db.Timeouts{})
+	defer dbpool.Close()
+
+	store := db.NewPostgresStore(dbpool, db.Timeouts{}, "simple")
+	if args[1] != models.RoleUser {
+		user, err := store.GetUserByEmail(ctx, args[0])
+		if err != nil {
+			slog.Error("Failed get user", "email", args[0], "error", err)
+			return 1
+		}
+
+		totp, err := store.GetTOTP(ctx, user.ID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			slog.Error("Failed get authenticator", "email", args[0], "error", err)
+			return 1
+		}
+		if !totp.Enabled() {
+			slog.Error("User must enable two-factor authentication first (POST /api/me/2fa)", "email", args[0])
+			return 1
+		}
+	}
+
+	if err := store.SetUserRoleByEmail(ctx, args[0], args[1]); err != nil {
+		slog.Error("Failed set user role", "email", args[0], "error", err)
+		return 1
+	}
+
+	slog.Info("User role changed", "email", args[0], "role", args[1])
+	return 0
+}
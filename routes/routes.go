@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"gopher-post/config"
 	"gopher-post/handlers"
 	"gopher-post/middleware"
+	"gopher-post/models"
+	"net/http"
 
 	"github.com/gorilla/mux"
 
@@ -19,24 +22,80 @@ func SetupRoutes(srv *handlers.Server) *mux.Router {
 
 	router.HandleFunc("/login", srv.LoginHandler).Methods("POST")
 	router.HandleFunc("/register", srv.CreateUserHandler).Methods("POST")
-	router.HandleFunc("/posts", srv.GetPostAllHandler).Methods("GET")
-	router.HandleFunc("/posts/{id}", srv.GetPostByIDHandler).Methods("GET")
-	router.HandleFunc("/posts/{id}/comments", srv.GetCommentHandler).Methods("GET")
+	router.HandleFunc("/auth/refresh", srv.RefreshHandler).Methods("POST")
+	router.HandleFunc("/auth/logout", srv.LogoutHandler).Methods("POST")
+	router.HandleFunc("/auth/forgot-password", srv.ForgotPasswordHandler).Methods("POST")
+	router.HandleFunc("/auth/reset-password", srv.ResetPasswordHandler).Methods("POST")
+	router.HandleFunc("/auth/verify-email", srv.VerifyEmailHandler).Methods("POST")
+	router.HandleFunc("/auth/2fa", srv.TwoFactorLoginHandler).Methods("POST")
+	router.HandleFunc("/auth/magic-link", srv.MagicLinkHandler).Methods("POST")
+	router.HandleFunc("/auth/magic-link/login", srv.MagicLinkLoginHandler).Methods("POST")
+	router.HandleFunc("/auth/oidc/{provider}/login", srv.OIDCLoginHandler).Methods("GET")
+	router.HandleFunc("/auth/oidc/{provider}/callback", srv.OIDCCallbackHandler).Methods("GET")
+	// Cache-Control policies of the public reads. They are always
+	// revalidated, which stays cheap thanks to ETag and 304 responses. A
+	// post served from cache unchecked would hand out a stale ETag and make
+	// If-Match updates fail.
+	listCache := middleware.CacheMiddleware(config.String("CACHE_CONTROL_LISTS", "public, no-cache"))
+	postCache := middleware.CacheMiddleware(config.String("CACHE_CONTROL_POST", "public, no-cache"))
+
+	router.Handle("/posts", listCache(http.HandlerFunc(srv.GetPostAllHandler))).Methods("GET")
+	router.Handle("/posts/{id}", postCache(http.HandlerFunc(srv.GetPostByIDHandler))).Methods("GET")
+	router.Handle("/posts/{id}/comments", listCache(http.HandlerFunc(srv.GetCommentHandler))).Methods("GET")
+	router.HandleFunc("/posts/{id}/revisions", srv.GetPostRevisionsHandler).Methods("GET")
+	router.HandleFunc("/posts/{id}/revisions/diff", srv.DiffPostRevisionsHandler).Methods("GET")
+	router.HandleFunc("/posts/{id}/revisions/{rev:[0-9]+}", srv.GetPostRevisionHandler).Methods("GET")
+	router.HandleFunc("/search", srv.SearchHandler).Methods("GET")
+	router.Handle("/.well-known/jwks.json", middleware.CacheMiddleware("public, max-age=300")(http.HandlerFunc(srv.JWKSHandler))).Methods("GET")
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 	api := router.PathPrefix("/api").Subrouter()
-	api.Use(middleware.AuthMiddleware)
+	api.Use(middleware.NewAuthMiddleware(srv.Keys, srv.Sessions, srv.PersonalTokens, srv.TokenVersions))
+
+	// personal access tokens only reach routes wrapped in a scope, every
+	// other route needs a login. Who may change or delete a post or comment
+	// depends on its author, so those routes are authorized per item by
+	// policy.Default inside the handlers: authors, and for deletes also
+	// moderators and admins.
+	postsWrite := middleware.RequireScope(models.ScopePostsWrite)
+	commentsWrite := middleware.RequireScope(models.ScopeCommentsWrite)
+
+	api.Handle("/posts", postsWrite(http.HandlerFunc(srv.CreatePostHandler))).Methods("POST")
+	api.Handle("/posts/{id}", postsWrite(http.HandlerFunc(srv.UpdatePostHandler))).Methods("PUT")
+	api.Handle("/posts/{id}", postsWrite(http.HandlerFunc(srv.DeletePostHandler))).Methods("DELETE")
+	api.Handle("/posts/{id}/revisions/{rev:[0-9]+}/rollback", postsWrite(http.HandlerFunc(srv.RollbackPostHandler))).Methods("POST")
+	api.Handle("/posts/{id}/comments", commentsWrite(http.HandlerFunc(srv.CreateCommentHandler))).Methods("POST")
+	api.Handle("/comments/{id}", commentsWrite(http.HandlerFunc(srv.DeleteCommentHandler))).Methods("DELETE")
+
+	api.HandleFunc("/me/trash", srv.GetTrashHandler).Methods("GET")
+	api.HandleFunc("/me/trash/posts/{id}/restore", srv.RestorePostHandler).Methods("POST")
+	api.HandleFunc("/me/trash/comments/{id}/restore", srv.RestoreCommentHandler).Methods("POST")
+
+	api.HandleFunc("/me/password", srv.ChangePasswordHandler).Methods("PUT")
+	api.HandleFunc("/me/email/verification", srv.ResendVerificationHandler).Methods("POST")
+	api.HandleFunc("/me/magic-link", srv.SetMagicLinkHandler).Methods("PUT")
+	api.HandleFunc("/me/2fa", srv.GetTwoFactorHandler).Methods("GET")
+	api.HandleFunc("/me/2fa", srv.EnrollTwoFactorHandler).Methods("POST")
+	api.HandleFunc("/me/2fa", srv.DisableTwoFactorHandler).Methods("DELETE")
+	api.HandleFunc("/me/2fa/confirm", srv.ConfirmTwoFactorHandler).Methods("POST")
+	api.HandleFunc("/me/sessions", srv.GetSessionsHandler).Methods("GET")
+	api.HandleFunc("/me/sessions", srv.RevokeAllSessionsHandler).Methods("DELETE")
+	api.HandleFunc("/me/sessions/{id}", srv.RevokeSessionHandler).Methods("DELETE")
+	api.HandleFunc("/me/tokens", srv.GetPersonalTokensHandler).Methods("GET")
+	api.HandleFunc("/me/tokens", srv.CreatePersonalTokenHandler).Methods("POST")
+	api.HandleFunc("/me/tokens/{id}", srv.RevokePersonalTokenHandler).Methods("DELETE")
 
-	api.HandleFunc("/posts", srv.CreatePostHandler).Methods("POST")
-	api.HandleFunc("/posts/{id}", srv.UpdatePostHandler).Methods("PUT")
-	api.HandleFunc("/posts/{id}", srv.DeletePostHandler).Methods("DELETE")
-	api.HandleFunc("/posts/{id}/comments", srv.CreateCommentHandler).Methods("POST")
-	api.HandleFunc("/comments/{id}", srv.DeleteCommentHandler).Methods("DELETE")
+	// role requirements of the user management routes, the handlers check
+	// them again through policy.Default
+	selfOrAdmin := middleware.RequireSelfOrRole("id", models.RoleAdmin)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
 
 	api.HandleFunc("/users", srv.GetUserAllHandler).Methods("GET")
 	api.HandleFunc("/users/{id}", srv.GetUserByIDHandler).Methods("GET")
-	api.HandleFunc("/users/{id}", srv.UpdateUserHandler).Methods("PUT")
-	api.HandleFunc("/users/{id}", srv.DeleteUserHandler).Methods("DELETE")
+	api.Handle("/users/{id}", selfOrAdmin(http.HandlerFunc(srv.UpdateUserHandler))).Methods("PUT")
+	api.Handle("/users/{id}", selfOrAdmin(http.HandlerFunc(srv.DeleteUserHandler))).Methods("DELETE")
+	api.Handle("/users/{id}/role", adminOnly(http.HandlerFunc(srv.SetUserRoleHandler))).Methods("PUT")
+	api.Handle("/users/{id}/disabled", adminOnly(http.HandlerFunc(srv.SetUserDisabledHandler))).Methods("PUT")
 
 	return router
 }
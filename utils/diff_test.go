@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineDiff(t *testing.T) {
+	diff := LineDiff("satu\ndua\ntiga", "satu\nDUA\ntiga\nempat")
+
+	assert.Equal(t, []DiffLine{
+		{Op: DiffEqual, Text: "satu"},
+		{Op: DiffDelete, Text: "dua"},
+		{Op: DiffInsert, Text: "DUA"},
+		{Op: DiffEqual, Text: "tiga"},
+		{Op: DiffInsert, Text: "empat"},
+	}, diff)
+}
+
+func TestLineDiffIdenticalAndEmpty(t *testing.T) {
+	assert.Equal(t, []DiffLine{{Op: DiffEqual, Text: "sama"}}, LineDiff("sama", "sama"))
+	assert.Equal(t, []DiffLine{{Op: DiffInsert, Text: "baru"}}, LineDiff("", "baru"))
+	assert.Empty(t, LineDiff("", ""))
+}
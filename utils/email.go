@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// ValidEmail reports whether email is a bare address like "gopher@example.com"
+// with a dotted domain. Display names and angle brackets are rejected.
+func ValidEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+
+	_, domain, _ := strings.Cut(email, "@")
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, ".")
+}
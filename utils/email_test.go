@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidEmail(t *testing.T) {
+	assert.True(t, ValidEmail("gopher@example.com"))
+	assert.True(t, ValidEmail("gopher.post+tag@mail.example.co.id"))
+
+	// bukan alamat email biasa
+	assert.False(t, ValidEmail(""))
+	assert.False(t, ValidEmail("gopher"))
+	assert.False(t, ValidEmail("gopher@localhost"))
+	assert.False(t, ValidEmail("gopher@example."))
+	assert.False(t, ValidEmail("Gopher <gopher@example.com>"))
+	assert.False(t, ValidEmail(" gopher@example.com"))
+	assert.False(t, ValidEmail(strings.Repeat("a", 250)+"@example.com"))
+}
@@ -1,13 +1,33 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"gopher-post/passhash"
+	"sync/atomic"
+)
+
+var passwords atomic.Pointer[passhash.Registry]
+
+func init() {
+	passwords.Store(passhash.New(passhash.DefaultArgon2id))
+}
+
+// SetPasswordHasher changes the algorithm of new hashes. Existing hashes of
+// any known algorithm keep verifying.
+func SetPasswordHasher(registry *passhash.Registry) {
+	passwords.Store(registry)
+}
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	return passwords.Load().Hash(password)
 }
 
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	match, _, err := passwords.Load().Verify(password, hash)
+	return err == nil && match
+}
+
+// PasswordNeedsRehash reports whether hash should be replaced by a new
+// HashPassword of the password, because the algorithm or its cost changed.
+func PasswordNeedsRehash(hash string) bool {
+	return passwords.Load().NeedsRehash(hash)
 }
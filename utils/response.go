@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"gopher-post/models"
 	"net/http"
 )
 
@@ -16,7 +17,62 @@ type ErrorResponse struct {
 
 type LoginResponse struct {
 	Message string `json:"message"`
-	Token   string `json:"token"`
+	// Token is the short-lived access token for the Authorization header.
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of Token in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// MFAChallengeResponse is returned by a login that still needs a second
+// factor. MFAToken is submitted to POST /auth/2fa together with a code.
+type MFAChallengeResponse struct {
+	Message  string `json:"message"`
+	MFAToken string `json:"mfa_token"`
+	// ExpiresIn is the lifetime of MFAToken in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// TwoFactorEnrollResponse holds a new authenticator secret. OTPAuthURI is
+// meant to be shown as a QR code.
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse lists one-time recovery codes. They are only shown
+// once.
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// PersonalTokenResponse holds a new personal access token. Token is only
+// shown once.
+type PersonalTokenResponse struct {
+	Message       string               `json:"message"`
+	Token         string               `json:"token"`
+	PersonalToken models.PersonalToken `json:"personal_token"`
+}
+
+// PageResponse is the envelope for every cursor paginated listing.
+type PageResponse[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// RevisionDiffResponse is the line-level diff between two post revisions.
+type RevisionDiffResponse struct {
+	From    int        `json:"from"`
+	To      int        `json:"to"`
+	Title   []DiffLine `json:"title"`
+	Content []DiffLine `json:"content"`
 }
 
 // -- Helper Function --
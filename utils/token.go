@@ -1,27 +1,67 @@
 package utils
 
 import (
-	"os"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"gopher-post/models"
+	"strings"
 )
 
-func CreateToken(userID string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	var jwtKey = []byte(secret)
-
-	claim := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+// NewOpaqueToken returns a random URL-safe token together with the hash to
+// store in its place.
+func NewOpaqueToken() (token string, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
 
-	tokenString, err := token.SignedString(jwtKey)
+// NewPersonalToken returns a new personal access token, recognisable by its
+// prefix, and its hash.
+func NewPersonalToken() (token string, hash string, err error) {
+	token, _, err = NewOpaqueToken()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return tokenString, nil
+	token = models.PersonalTokenPrefix + token
+	return token, HashToken(token), nil
+}
+
+// HashToken is the SHA-256 digest stored for opaque tokens. Tokens carry 256
+// bits of entropy, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRecoveryCode returns a random two-factor recovery code formatted as
+// "xxxx-xxxx-xxxx-xxxx" together with the hash to store.
+func NewRecoveryCode() (code string, hash string, err error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+	code = encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+	return code, HashRecoveryCode(code), nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user, ignoring
+// case, spaces and dashes.
+func HashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	return HashToken(normalized)
 }
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecoveryCode(t *testing.T) {
+	code, hash, err := NewRecoveryCode()
+	require.NoError(t, err)
+	assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`, code)
+
+	// huruf besar, spasi, dan tanpa tanda hubung tetap cocok
+	assert.Equal(t, hash, HashRecoveryCode(strings.ToUpper(code)))
+	assert.Equal(t, hash, HashRecoveryCode(strings.ReplaceAll(code, "-", " ")))
+	assert.Equal(t, hash, HashRecoveryCode(strings.ReplaceAll(code, "-", "")))
+
+	other, _, err := NewRecoveryCode()
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
@@ -0,0 +1,107 @@
+// Package workpool bounds how much CPU-heavy work, like password hashing,
+// runs at once. Callers beyond the workers wait in a bounded queue; once the
+// queue is full they are turned away instead of piling up.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrFull is returned by Do when every worker is busy and the queue is full.
+var ErrFull = errors.New("work pool is full")
+
+// Pool runs functions on at most a fixed number of workers. The zero value
+// is not usable, see New.
+type Pool struct {
+	workers  chan struct{}
+	admitted chan struct{}
+
+	running   atomic.Int64
+	queued    atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+	waitNanos atomic.Int64
+	maxWait   atomic.Int64
+}
+
+// New returns a Pool of workers that lets up to queue callers wait for a
+// free worker.
+func New(workers int, queue int) *Pool {
+	workers = max(workers, 1)
+	return &Pool{
+		workers:  make(chan struct{}, workers),
+		admitted: make(chan struct{}, workers+max(queue, 0)),
+	}
+}
+
+// Do runs fn on the caller's goroutine once a worker is free. It returns
+// ErrFull without waiting when the queue is full, and ctx.Err() when ctx is
+// done before a worker frees up.
+func (p *Pool) Do(ctx context.Context, fn func()) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		p.rejected.Add(1)
+		return ErrFull
+	}
+	defer func() { <-p.admitted }()
+
+	start := time.Now()
+	p.queued.Add(1)
+	select {
+	case p.workers <- struct{}{}:
+		p.queued.Add(-1)
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return ctx.Err()
+	}
+	defer func() { <-p.workers }()
+	p.recordWait(time.Since(start))
+
+	p.running.Add(1)
+	defer p.running.Add(-1)
+	fn()
+	p.completed.Add(1)
+
+	return nil
+}
+
+func (p *Pool) recordWait(wait time.Duration) {
+	p.waitNanos.Add(int64(wait))
+	for {
+		current := p.maxWait.Load()
+		if int64(wait) <= current || p.maxWait.CompareAndSwap(current, int64(wait)) {
+			return
+		}
+	}
+}
+
+// Stats is a snapshot of a Pool, shaped for expvar.
+type Stats struct {
+	Workers   int   `json:"workers"`
+	QueueSize int   `json:"queue_size"`
+	Running   int64 `json:"running"`
+	Queued    int64 `json:"queued"`
+	Completed int64 `json:"completed"`
+	Rejected  int64 `json:"rejected"`
+	// WaitSeconds is the total time callers waited for a worker, divide by
+	// Completed for the average.
+	WaitSeconds    float64 `json:"wait_seconds_total"`
+	MaxWaitSeconds float64 `json:"wait_seconds_max"`
+}
+
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:        cap(p.workers),
+		QueueSize:      cap(p.admitted) - cap(p.workers),
+		Running:        p.running.Load(),
+		Queued:         p.queued.Load(),
+		Completed:      p.completed.Load(),
+		Rejected:       p.rejected.Load(),
+		WaitSeconds:    time.Duration(p.waitNanos.Load()).Seconds(),
+		MaxWaitSeconds: time.Duration(p.maxWait.Load()).Seconds(),
+	}
+}
@@ -0,0 +1,87 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occupy menahan satu worker sampai release ditutup
+func occupy(t *testing.T, pool *Pool, release chan struct{}) {
+	t.Helper()
+	started := make(chan struct{})
+	go pool.Do(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+}
+
+func TestPoolBoundsWorkers(t *testing.T) {
+	pool := New(2, 10)
+
+	var mu sync.Mutex
+	var running, peak int
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			err := pool.Do(context.Background(), func() {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+			// 20 pemanggil melebihi 2 worker + 10 antrean, sebagian boleh ditolak
+			if err != nil {
+				assert.ErrorIs(t, err, ErrFull)
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, peak, 2)
+	stats := pool.Stats()
+	assert.EqualValues(t, 20, stats.Completed+stats.Rejected)
+	assert.Zero(t, stats.Running)
+	assert.Zero(t, stats.Queued)
+}
+
+func TestPoolRejectsWhenQueueFull(t *testing.T) {
+	pool := New(1, 1)
+	release := make(chan struct{})
+	occupy(t, pool, release)
+
+	queued := make(chan error)
+	go func() { queued <- pool.Do(context.Background(), func() {}) }()
+	require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	assert.ErrorIs(t, pool.Do(context.Background(), func() { t.Error("should not run") }), ErrFull)
+	assert.EqualValues(t, 1, pool.Stats().Rejected)
+
+	close(release)
+	require.NoError(t, <-queued)
+	stats := pool.Stats()
+	assert.EqualValues(t, 2, stats.Completed)
+	assert.Positive(t, stats.MaxWaitSeconds)
+}
+
+func TestPoolStopsWaitingOnContext(t *testing.T) {
+	pool := New(1, 5)
+	release := make(chan struct{})
+	defer close(release)
+	occupy(t, pool, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pool.Do(ctx, func() { t.Error("should not run") })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, pool.Stats().Queued)
+}